@@ -0,0 +1,171 @@
+// Copyright (c) 2014, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package cirq
+
+import (
+	"slices"
+	"sort"
+)
+
+// Swap swaps the i'th and the j'th elements of the queue, counting
+// from the front (head) of the queue. Panics if i or j are out of
+// range.
+func (cq *Queue[T]) Swap(i, j int) {
+	n := int(cq.e - cq.s)
+	if i < 0 || i >= n || j < 0 || j >= n {
+		panic("Swap Q index out of range")
+	}
+	ki, kj := (cq.s+uint(i))&cq.m, (cq.s+uint(j))&cq.m
+	cq.b[ki], cq.b[kj] = cq.b[kj], cq.b[ki]
+}
+
+// sorter adapts a Queue to sort.Interface.
+type sorter[T any] struct {
+	cq   *Queue[T]
+	less func(a, b T) bool
+}
+
+func (s sorter[T]) Len() int { return s.cq.Len() }
+
+func (s sorter[T]) Swap(i, j int) { s.cq.Swap(i, j) }
+
+func (s sorter[T]) Less(i, j int) bool {
+	cq := s.cq
+	return s.less(cq.b[(cq.s+uint(i))&cq.m], cq.b[(cq.s+uint(j))&cq.m])
+}
+
+// Sorter returns a sort.Interface for the queue, where elements are
+// compared with the function less. It can be used with the functions
+// of package sort (e.g. sort.Sort, sort.Stable, sort.IsSorted) to
+// operate on the queue in-place. Element indexes are counted from the
+// front (head) of the queue. The queue must not be modified while the
+// sort.Interface is used.
+func (cq *Queue[T]) Sorter(less func(a, b T) bool) sort.Interface {
+	return sorter[T]{cq, less}
+}
+
+// linearize moves the queue elements, in-place, so that they are
+// stored contiguously, starting at the beginning of the queue
+// slice. It returns the slice of the elements.
+func (cq *Queue[T]) linearize() []T {
+	n := cq.e - cq.s
+	if si := cq.s & cq.m; si+n > cq.sz {
+		// Rotate the queue slice left by si positions.
+		slices.Reverse(cq.b[:si])
+		slices.Reverse(cq.b[si:])
+		slices.Reverse(cq.b)
+		cq.s, cq.e = 0, n
+		cq.mod++
+	}
+	si := cq.s & cq.m
+	return cq.b[si : si+n]
+}
+
+// SortFunc sorts the elements of the queue in ascending order, as
+// determined by the function cmp (see slices.SortFunc). The sort is
+// not guaranteed to be stable. If the elements wrap around the end of
+// the queue slice, they are first moved (in-place) so that they are
+// stored contiguously.
+func (cq *Queue[T]) SortFunc(cmp func(a, b T) int) {
+	slices.SortFunc(cq.linearize(), cmp)
+}
+
+// SortStableFunc is similar to SortFunc, but keeps the original order
+// of equal elements.
+func (cq *Queue[T]) SortStableFunc(cmp func(a, b T) int) {
+	slices.SortStableFunc(cq.linearize(), cmp)
+}
+
+// BinarySearchFunc searches for target in the queue, which must be
+// sorted in ascending order, as determined by the function cmp (see
+// slices.BinarySearchFunc). It returns the index where target is
+// found, or the index where it would be inserted (see InsertAt), and
+// a bool indicating whether target was found.
+func (cq *Queue[T]) BinarySearchFunc(target T,
+	cmp func(a, b T) int) (i int, found bool) {
+	n := int(cq.e - cq.s)
+	i = sort.Search(n, func(i int) bool {
+		return cmp(cq.b[(cq.s+uint(i))&cq.m], target) >= 0
+	})
+	return i, i < n && cmp(cq.b[(cq.s+uint(i))&cq.m], target) == 0
+}
+
+// Rotate rotates the queue by k positions: For k > 0, the k front
+// elements are moved to the back of the queue, in order (as if they
+// were popped from the front and pushed at the back). For k < 0, the
+// -k back elements are moved to the front. Values of k larger than
+// the queue length are taken modulo it. Rotate moves at most Len()/2
+// elements, and no elements at all if the queue is full.
+func (cq *Queue[T]) Rotate(k int) {
+	var zero T
+	n := int(cq.e - cq.s)
+	if n == 0 {
+		return
+	}
+	if k %= n; k < 0 {
+		k += n
+	}
+	if k == 0 {
+		return
+	}
+	cq.mod++
+	if uint(n) == cq.sz {
+		cq.s += uint(k)
+		cq.e += uint(k)
+		return
+	}
+	if k <= n/2 {
+		for ; k > 0; k-- {
+			cq.b[cq.e&cq.m] = cq.b[cq.s&cq.m]
+			cq.b[cq.s&cq.m] = zero
+			cq.s++
+			cq.e++
+		}
+	} else {
+		for k = n - k; k > 0; k-- {
+			cq.s--
+			cq.e--
+			cq.b[cq.s&cq.m] = cq.b[cq.e&cq.m]
+			cq.b[cq.e&cq.m] = zero
+		}
+	}
+}
+
+// Reverse reverses the order of the elements of the queue.
+func (cq *Queue[T]) Reverse() {
+	n := cq.e - cq.s
+	for i := uint(0); i < n/2; i++ {
+		ki, kj := (cq.s+i)&cq.m, (cq.e-1-i)&cq.m
+		cq.b[ki], cq.b[kj] = cq.b[kj], cq.b[ki]
+	}
+}
+
+// Filter removes, in-place, the elements of the queue for which the
+// function keep returns false. The remaining elements keep their
+// order. Returns the number of elements removed. If automatic
+// shrinking is enabled (see Options), the queue may be shrunk.
+func (cq *Queue[T]) Filter(keep func(el T) bool) (n int) {
+	w := cq.s
+	for r := cq.s; r != cq.e; r++ {
+		if keep(cq.b[r&cq.m]) {
+			if w != r {
+				cq.b[w&cq.m] = cq.b[r&cq.m]
+			}
+			w++
+		}
+	}
+	n = int(cq.e - w)
+	if n == 0 {
+		return 0
+	}
+	cq.clearOut(w, cq.e-w)
+	cq.e = w
+	cq.mod++
+	if cq.shr {
+		cq.shrink()
+	}
+	return n
+}
@@ -0,0 +1,132 @@
+package cirq
+
+import (
+	"cmp"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// mkRandom returns a wrapped queue holding n random integers in
+// [0, n), and a slice with the same elements.
+func mkRandom(n int) (*Queue[int], []int) {
+	q := mkWrapped(n)
+	s := make([]int, n)
+	for i := range s {
+		s[i] = rand.Intn(n)
+		q.Set(i, s[i])
+	}
+	return q, s
+}
+
+func TestSort(t *testing.T) {
+	q, s := mkRandom(100)
+	sort.Sort(q.Sorter(func(a, b int) bool { return a < b }))
+	slices.Sort(s)
+	checkSeq(t, q, s)
+
+	q, s = mkRandom(100)
+	q.SortFunc(cmp.Compare[int])
+	slices.Sort(s)
+	checkSeq(t, q, s)
+	if !sort.IsSorted(q.Sorter(cmp.Less[int])) {
+		t.Fatal("Q not sorted")
+	}
+	q.PushBack(1000)
+	checkSeq(t, q, append(s, 1000))
+
+	type kv struct{ k, v int }
+	kq := NewQueue[kv](4, 64)
+	for i := 0; i < 40; i++ {
+		kq.PushFront(kv{i % 4, i})
+	}
+	kq.SortStableFunc(func(a, b kv) int { return cmp.Compare(a.k, b.k) })
+	for i := 1; i < kq.Len(); i++ {
+		a, b := kq.At(i-1), kq.At(i)
+		if a.k > b.k || a.k == b.k && a.v < b.v {
+			t.Fatalf("Elements %d, %d not stable-sorted: %v, %v",
+				i-1, i, a, b)
+		}
+	}
+}
+
+func TestBinarySearch(t *testing.T) {
+	q := mkWrapped(100)
+	q.Filter(func(el int) bool { return el%2 == 0 })
+	for v := -1; v <= 100; v++ {
+		i, found := q.BinarySearchFunc(v, cmp.Compare[int])
+		wi, wfound := slices.BinarySearch(q.Slice(), v)
+		if i != wi || found != wfound {
+			t.Fatalf("Search %d: %d, %v != %d, %v",
+				v, i, found, wi, wfound)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	for _, full := range []bool{false, true} {
+		for k := -12; k <= 12; k++ {
+			q := NewQueue[int](8, 8)
+			q.PushBack(0)
+			q.PopFront()
+			n := 5
+			if full {
+				n = 8
+			}
+			q.PushBackSlice(seq(0, n))
+			q.Rotate(k)
+			s := seq(0, n)
+			r := ((k % n) + n) % n
+			s = append(s[r:], s[:r]...)
+			checkSeq(t, q, s)
+			if q.Cap() != 8 {
+				t.Fatalf("Q cap %d != 8", q.Cap())
+			}
+		}
+	}
+	q := NewQueue[int](1, 1)
+	q.Rotate(3)
+	if !q.Empty() {
+		t.Fatal("Rotated empty Q not empty")
+	}
+}
+
+func TestReverse(t *testing.T) {
+	for n := 0; n < 10; n++ {
+		q := mkWrapped(n)
+		q.Reverse()
+		s := seq(0, n)
+		slices.Reverse(s)
+		checkSeq(t, q, s)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	q := mkWrapped(100)
+	if n := q.Filter(func(el int) bool { return el%3 == 0 }); n != 66 {
+		t.Fatalf("Filter removed %d != 66", n)
+	}
+	s := slices.DeleteFunc(seq(0, 100),
+		func(el int) bool { return el%3 != 0 })
+	checkSeq(t, q, s)
+	for i := q.Len(); i < q.Cap(); i++ {
+		if q.b[(q.s+uint(i))&q.m] != 0 {
+			t.Fatalf("Slot %d not cleared", i)
+		}
+	}
+	if n := q.Filter(func(el int) bool { return true }); n != 0 {
+		t.Fatalf("Filter removed %d != 0", n)
+	}
+
+	q, err := NewQueueOpts[int](4, 1024, &Options{Shrink: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.PushBackSlice(seq(0, 100))
+	q.Filter(func(el int) bool { return el < 10 })
+	checkSeq(t, q, seq(0, 10))
+	if q.Cap() != 32 {
+		t.Fatalf("Q cap %d != 32", q.Cap())
+	}
+}
@@ -0,0 +1,100 @@
+package cirq_test
+
+import (
+	"testing"
+
+	"github.com/npat-efault/gohacks/cirq"
+)
+
+// Compare the generic queue, instantiated for ints, against the
+// specialized int queue generated by cirqgen (intCQ), and the
+// interface{} queue (CQ).
+//
+// run with:
+//   go test -run=XXX -benchmem -bench=.
+
+const benchSz = 1024
+
+func BenchmarkFIFOGeneric(b *testing.B) {
+	q := cirq.NewQueue[int](benchSz, benchSz)
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < benchSz; j++ {
+			q.PushBack(j)
+		}
+		for j := 0; j < benchSz; j++ {
+			if v, _ := q.PopFront(); v != j {
+				b.Fatalf("Bad element %d != %d", v, j)
+			}
+		}
+	}
+}
+
+func BenchmarkFIFOGenerated(b *testing.B) {
+	q := newIntCQ(benchSz, benchSz)
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < benchSz; j++ {
+			q.PushBack(j)
+		}
+		for j := 0; j < benchSz; j++ {
+			if v, _ := q.PopFront(); v != j {
+				b.Fatalf("Bad element %d != %d", v, j)
+			}
+		}
+	}
+}
+
+func BenchmarkFIFOIface(b *testing.B) {
+	q := cirq.New(benchSz, benchSz)
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < benchSz; j++ {
+			q.PushBack(j)
+		}
+		for j := 0; j < benchSz; j++ {
+			if v, _ := q.PopFront(); v.(int) != j {
+				b.Fatalf("Bad element %d != %d", v, j)
+			}
+		}
+	}
+}
+
+func BenchmarkLIFOGeneric(b *testing.B) {
+	q := cirq.NewQueue[int](benchSz, benchSz)
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < benchSz; j++ {
+			q.PushFront(j)
+		}
+		for j := 0; j < benchSz; j++ {
+			q.PopFront()
+		}
+	}
+}
+
+func BenchmarkLIFOGenerated(b *testing.B) {
+	q := newIntCQ(benchSz, benchSz)
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < benchSz; j++ {
+			q.PushFront(j)
+		}
+		for j := 0; j < benchSz; j++ {
+			q.PopFront()
+		}
+	}
+}
+
+func BenchmarkGrowGeneric(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		q := cirq.NewQueue[int](1, benchSz)
+		for j := 0; j < benchSz; j++ {
+			q.PushBack(j)
+		}
+	}
+}
+
+func BenchmarkGrowGenerated(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		q := newIntCQ(1, benchSz)
+		for j := 0; j < benchSz; j++ {
+			q.PushBack(j)
+		}
+	}
+}
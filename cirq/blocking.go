@@ -0,0 +1,223 @@
+// Copyright (c) 2014, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package cirq
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrClosed = errors.New("Queue closed")
+	ErrFull   = errors.New("Queue full")
+	ErrEmpty  = errors.New("Queue empty")
+)
+
+// BlockingQueue is a thread-safe FIFO queue, built on Queue. Elements
+// are pushed at the back and popped from the front. Push blocks while
+// the queue is full (holds maxSz elements), and Pop blocks while the
+// queue is empty. Both can be canceled through a context.Context.
+//
+// All BlockingQueue methods can be called concurrently from multiple
+// goroutines.
+type BlockingQueue[T any] struct {
+	m        sync.Mutex
+	q        *Queue[T]
+	closed   bool
+	nPushW   int           /* # of goroutines waiting to push */
+	nPopW    int           /* # of goroutines waiting to pop */
+	notFull  chan struct{} /* closed when an element is removed */
+	notEmpty chan struct{} /* closed when an element is added */
+}
+
+// NewBlockingQueue creates and returns a new blocking queue. The
+// queue is initially allocated with space for sz elements. It can
+// grow, when required, to accomodate up to maxSz elements. See
+// NewQueue for details.
+func NewBlockingQueue[T any](sz, maxSz int) *BlockingQueue[T] {
+	bq, err := NewBlockingQueueOpts[T](sz, maxSz, nil)
+	if err != nil {
+		panic(err.Error())
+	}
+	return bq
+}
+
+// NewBlockingQueueOpts is similar to NewBlockingQueue, but also
+// accepts optional parameters for the underlying queue, and returns
+// an error instead of panicking. See NewQueueOpts for details.
+func NewBlockingQueueOpts[T any](sz, maxSz int,
+	opts *Options) (*BlockingQueue[T], error) {
+	q, err := NewQueueOpts[T](sz, maxSz, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &BlockingQueue[T]{
+		q:        q,
+		notFull:  make(chan struct{}),
+		notEmpty: make(chan struct{}),
+	}, nil
+}
+
+// wakePush wakes-up the goroutines waiting to push, if any. Must be
+// called with bq.m held.
+func (bq *BlockingQueue[T]) wakePush() {
+	if bq.nPushW > 0 && !bq.closed {
+		close(bq.notFull)
+		bq.notFull = make(chan struct{})
+	}
+}
+
+// wakePop wakes-up the goroutines waiting to pop, if any. Must be
+// called with bq.m held.
+func (bq *BlockingQueue[T]) wakePop() {
+	if bq.nPopW > 0 && !bq.closed {
+		close(bq.notEmpty)
+		bq.notEmpty = make(chan struct{})
+	}
+}
+
+// Push adds element el to the back of the queue. If the queue is
+// full, Push waits until space becomes available, the queue is
+// closed, or ctx is done. Returns nil if the element was added,
+// ErrClosed if the queue was closed, or ctx.Err() if ctx was done
+// before the element could be added.
+func (bq *BlockingQueue[T]) Push(ctx context.Context, el T) error {
+	bq.m.Lock()
+	for {
+		if bq.closed {
+			bq.m.Unlock()
+			return ErrClosed
+		}
+		if bq.q.PushBack(el) {
+			bq.wakePop()
+			bq.m.Unlock()
+			return nil
+		}
+		bq.nPushW++
+		ch := bq.notFull
+		bq.m.Unlock()
+		var err error
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		bq.m.Lock()
+		bq.nPushW--
+		if err != nil {
+			bq.m.Unlock()
+			return err
+		}
+	}
+}
+
+// Pop removes the front element from the queue and returns it. If the
+// queue is empty, Pop waits until an element becomes available, the
+// queue is closed, or ctx is done. Returns ErrClosed if the queue is
+// closed and empty, or ctx.Err() if ctx was done before an element
+// could be removed. Elements that remain in the queue after it has
+// been closed can still be popped.
+func (bq *BlockingQueue[T]) Pop(ctx context.Context) (el T, err error) {
+	bq.m.Lock()
+	for {
+		var ok bool
+		if el, ok = bq.q.PopFront(); ok {
+			bq.wakePush()
+			bq.m.Unlock()
+			return el, nil
+		}
+		if bq.closed {
+			bq.m.Unlock()
+			return el, ErrClosed
+		}
+		bq.nPopW++
+		ch := bq.notEmpty
+		bq.m.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		bq.m.Lock()
+		bq.nPopW--
+		if err != nil {
+			bq.m.Unlock()
+			return el, err
+		}
+	}
+}
+
+// TryPush adds element el to the back of the queue, without
+// waiting. Returns ErrFull if the queue is full, ErrClosed if the
+// queue is closed, nil otherwise.
+func (bq *BlockingQueue[T]) TryPush(el T) error {
+	bq.m.Lock()
+	defer bq.m.Unlock()
+	if bq.closed {
+		return ErrClosed
+	}
+	if !bq.q.PushBack(el) {
+		return ErrFull
+	}
+	bq.wakePop()
+	return nil
+}
+
+// TryPop removes the front element from the queue and returns it,
+// without waiting. Returns ErrEmpty if the queue is empty, or
+// ErrClosed if the queue is empty and closed.
+func (bq *BlockingQueue[T]) TryPop() (el T, err error) {
+	bq.m.Lock()
+	defer bq.m.Unlock()
+	var ok bool
+	if el, ok = bq.q.PopFront(); !ok {
+		if bq.closed {
+			return el, ErrClosed
+		}
+		return el, ErrEmpty
+	}
+	bq.wakePush()
+	return el, nil
+}
+
+// Close closes the queue and wakes-up all goroutines waiting on
+// it. After Close, pushes fail with ErrClosed, while pops succeed
+// until the queue is drained, and fail with ErrClosed afterwards. It
+// is ok to call Close multiple times. After the first, subsequent
+// calls do nothing.
+func (bq *BlockingQueue[T]) Close() {
+	bq.m.Lock()
+	defer bq.m.Unlock()
+	if bq.closed {
+		return
+	}
+	bq.closed = true
+	close(bq.notFull)
+	close(bq.notEmpty)
+}
+
+// Closed tests if the queue has been closed.
+func (bq *BlockingQueue[T]) Closed() bool {
+	bq.m.Lock()
+	defer bq.m.Unlock()
+	return bq.closed
+}
+
+// Len returns the number of elements waiting in the queue.
+func (bq *BlockingQueue[T]) Len() int {
+	bq.m.Lock()
+	defer bq.m.Unlock()
+	return bq.q.Len()
+}
+
+// MaxCap returns the maximum capacity of the queue (max # of elements
+// allowed).
+func (bq *BlockingQueue[T]) MaxCap() int {
+	bq.m.Lock()
+	defer bq.m.Unlock()
+	return bq.q.MaxCap()
+}
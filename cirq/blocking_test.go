@@ -0,0 +1,123 @@
+package cirq
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBlockingTry(t *testing.T) {
+	bq := NewBlockingQueue[int](1, 4)
+	for i := 0; i < 4; i++ {
+		if err := bq.TryPush(i); err != nil {
+			t.Fatalf("Cannot push %d: %v", i, err)
+		}
+	}
+	if err := bq.TryPush(4); err != ErrFull {
+		t.Fatalf("Push to full Q: %v != %v", err, ErrFull)
+	}
+	for i := 0; i < 4; i++ {
+		v, err := bq.TryPop()
+		if err != nil {
+			t.Fatalf("Cannot pop %d: %v", i, err)
+		}
+		if v != i {
+			t.Fatalf("Bad element %d != %d", v, i)
+		}
+	}
+	if _, err := bq.TryPop(); err != ErrEmpty {
+		t.Fatalf("Pop from empty Q: %v != %v", err, ErrEmpty)
+	}
+}
+
+func TestBlockingProdCons(t *testing.T) {
+	const nProd, nEl = 4, 10000
+	bq := NewBlockingQueue[int](1, 16)
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for p := 0; p < nProd; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < nEl; i++ {
+				if err := bq.Push(ctx, p*nEl+i); err != nil {
+					t.Errorf("Cannot push: %v", err)
+					return
+				}
+			}
+		}(p)
+	}
+	go func() {
+		wg.Wait()
+		bq.Close()
+	}()
+
+	last := make([]int, nProd)
+	for i := range last {
+		last[i] = -1
+	}
+	n := 0
+	for {
+		v, err := bq.Pop(ctx)
+		if err == ErrClosed {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Cannot pop: %v", err)
+		}
+		p, i := v/nEl, v%nEl
+		if i != last[p]+1 {
+			t.Fatalf("Producer %d: bad element %d != %d",
+				p, i, last[p]+1)
+		}
+		last[p] = i
+		n++
+	}
+	if n != nProd*nEl {
+		t.Fatalf("Popped %d != %d elements", n, nProd*nEl)
+	}
+}
+
+func TestBlockingCtx(t *testing.T) {
+	bq := NewBlockingQueue[int](1, 1)
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+	if _, err := bq.Pop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Pop from empty Q: %v != %v",
+			err, context.DeadlineExceeded)
+	}
+	bq.Push(context.Background(), 0)
+	if err := bq.Push(ctx, 1); err != context.DeadlineExceeded {
+		t.Fatalf("Push to full Q: %v != %v",
+			err, context.DeadlineExceeded)
+	}
+	if bq.Len() != 1 {
+		t.Fatalf("Bad Q len %d != 1", bq.Len())
+	}
+}
+
+func TestBlockingClose(t *testing.T) {
+	bq := NewBlockingQueue[int](1, 1)
+	ctx := context.Background()
+	bq.Push(ctx, 0)
+	errc := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		go func() { errc <- bq.Push(ctx, 1) }()
+	}
+	time.Sleep(10 * time.Millisecond)
+	bq.Close()
+	bq.Close()
+	for i := 0; i < 4; i++ {
+		if err := <-errc; err != ErrClosed {
+			t.Fatalf("Push to closed Q: %v != %v", err, ErrClosed)
+		}
+	}
+	if v, err := bq.Pop(ctx); err != nil || v != 0 {
+		t.Fatalf("Pop from closed Q: %d, %v", v, err)
+	}
+	if _, err := bq.Pop(ctx); err != ErrClosed {
+		t.Fatalf("Pop from closed, empty Q: %v != %v", err, ErrClosed)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2014, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package cirq
+
+// reserve makes room, if possible, for n more elements in the
+// queue, growing it as required. Returns the number of elements (<=
+// n) that can be added to the queue.
+func (cq *Queue[T]) reserve(n int) uint {
+	if cq.shr {
+		cq.shrink()
+	}
+	l := cq.e - cq.s
+	if n > int(cq.maxSz-l) {
+		n = int(cq.maxSz - l)
+	}
+	if l+uint(n) > cq.sz {
+		cq.resize(roundUp2(l + uint(n)))
+	}
+	return uint(n)
+}
+
+// makeRoom makes room in the queue for the elements of s, dropping
+// elements from the back (if front == true) or the front (if front ==
+// false) of the queue, as required. If s has more than maxSz
+// elements, it is trimmed. Returns the, possibly trimmed, s.
+func (cq *Queue[T]) makeRoom(s []T, front bool) []T {
+	if len(s) > int(cq.maxSz) {
+		cq.drops += uint64(len(s)) - uint64(cq.maxSz)
+		if front {
+			s = s[:cq.maxSz]
+		} else {
+			s = s[len(s)-int(cq.maxSz):]
+		}
+	}
+	n := cq.reserve(len(s))
+	if k := uint(len(s)) - n; k > 0 {
+		if front {
+			cq.e -= k
+			cq.clearOut(cq.e, k)
+		} else {
+			cq.clearOut(cq.s, k)
+			cq.s += k
+		}
+		cq.drops += uint64(k)
+	}
+	return s
+}
+
+// copyIn copies the elements of s in the queue slice, starting at
+// (free running) index i. The caller must make sure there is enough
+// space.
+func (cq *Queue[T]) copyIn(i uint, s []T) {
+	n := copy(cq.b[i&cq.m:], s)
+	copy(cq.b, s[n:])
+}
+
+// copyOut copies len(dst) elements from the queue slice, starting at
+// (free running) index i, to dst. The caller must make sure there are
+// enough elements.
+func (cq *Queue[T]) copyOut(dst []T, i uint) {
+	n := copy(dst, cq.b[i&cq.m:])
+	copy(dst[n:], cq.b)
+}
+
+// clearOut zeroes n element slots of the queue slice, starting at
+// (free running) index i.
+func (cq *Queue[T]) clearOut(i, n uint) {
+	if n == 0 {
+		return
+	}
+	si, ei := i&cq.m, (i+n)&cq.m
+	if si < ei {
+		clear(cq.b[si:ei])
+	} else {
+		clear(cq.b[si:])
+		clear(cq.b[:ei])
+	}
+}
+
+// PushBackSlice adds the elements of s to the back (tail) of the
+// queue, in order (s[len(s)-1] becomes the back element). If the
+// queue cannot accomodate all of them, the first n elements of s are
+// added. Returns the number of elements added. In overwrite mode (see
+// SetOverwrite), elements are dropped from the front of the queue to
+// make room for all elements of s; if s holds more than MaxCap
+// elements, only the last MaxCap ones are kept, and the rest are
+// counted as dropped.
+func (cq *Queue[T]) PushBackSlice(s []T) int {
+	if cq.ovr {
+		l := len(s)
+		s = cq.makeRoom(s, false)
+		cq.copyIn(cq.e, s)
+		cq.e += uint(len(s))
+		cq.mod++
+		return l
+	}
+	n := cq.reserve(len(s))
+	if n == 0 {
+		return 0
+	}
+	cq.copyIn(cq.e, s[:n])
+	cq.e += n
+	cq.mod++
+	return int(n)
+}
+
+// PushFrontSlice adds the elements of s to the front (head) of the
+// queue, in order (s[0] becomes the front element). If the queue
+// cannot accomodate all of them, the first n elements of s are
+// added. Returns the number of elements added. In overwrite mode (see
+// SetOverwrite), elements are dropped from the back of the queue to
+// make room for all elements of s; if s holds more than MaxCap
+// elements, only the first MaxCap ones are kept, and the rest are
+// counted as dropped.
+func (cq *Queue[T]) PushFrontSlice(s []T) int {
+	if cq.ovr {
+		l := len(s)
+		s = cq.makeRoom(s, true)
+		cq.s -= uint(len(s))
+		cq.copyIn(cq.s, s)
+		cq.mod++
+		return l
+	}
+	n := cq.reserve(len(s))
+	if n == 0 {
+		return 0
+	}
+	cq.s -= n
+	cq.copyIn(cq.s, s[:n])
+	cq.mod++
+	return int(n)
+}
+
+// PeekFrontInto copies up to len(dst) elements from the front (head)
+// of the queue to dst, without removing them. Elements are copied in
+// order (dst[0] is the front element). Returns the number of elements
+// copied.
+func (cq *Queue[T]) PeekFrontInto(dst []T) int {
+	n := cq.e - cq.s
+	if len(dst) < int(n) {
+		n = uint(len(dst))
+	}
+	cq.copyOut(dst[:n], cq.s)
+	return int(n)
+}
+
+// PeekBackInto copies up to len(dst) elements from the back (tail) of
+// the queue to dst, without removing them. Elements are copied in
+// order (dst[n-1] is the back element). Returns the number, n, of
+// elements copied.
+func (cq *Queue[T]) PeekBackInto(dst []T) int {
+	n := cq.e - cq.s
+	if len(dst) < int(n) {
+		n = uint(len(dst))
+	}
+	cq.copyOut(dst[:n], cq.e-n)
+	return int(n)
+}
+
+// PopFrontInto removes up to len(dst) elements from the front (head)
+// of the queue and stores them in dst. Elements are stored in order
+// (dst[0] was the front element). Returns the number of elements
+// removed.
+func (cq *Queue[T]) PopFrontInto(dst []T) int {
+	n := uint(cq.PeekFrontInto(dst))
+	if n == 0 {
+		return 0
+	}
+	cq.clearOut(cq.s, n)
+	cq.s += n
+	cq.mod++
+	if cq.shr {
+		cq.shrink()
+	}
+	return int(n)
+}
+
+// PopBackInto removes up to len(dst) elements from the back (tail) of
+// the queue and stores them in dst. Elements are stored in order
+// (dst[n-1] was the back element). Returns the number, n, of
+// elements removed.
+func (cq *Queue[T]) PopBackInto(dst []T) int {
+	n := uint(cq.PeekBackInto(dst))
+	if n == 0 {
+		return 0
+	}
+	cq.e -= n
+	cq.clearOut(cq.e, n)
+	cq.mod++
+	if cq.shr {
+		cq.shrink()
+	}
+	return int(n)
+}
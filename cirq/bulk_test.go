@@ -0,0 +1,107 @@
+package cirq
+
+import "testing"
+
+func seq(from, n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = from + i
+	}
+	return s
+}
+
+func TestBulkPush(t *testing.T) {
+	q := NewQueue[int](2, 64)
+	q.PushBack(0)
+	q.PopFront() // move start away from zero
+	if n := q.PushBackSlice(seq(10, 20)); n != 20 {
+		t.Fatalf("PushBackSlice: %d != 20", n)
+	}
+	if n := q.PushFrontSlice(seq(0, 10)); n != 10 {
+		t.Fatalf("PushFrontSlice: %d != 10", n)
+	}
+	checkSeq(t, q, seq(0, 30))
+	if q.Cap() != 32 {
+		t.Fatalf("Q cap %d != 32", q.Cap())
+	}
+	// Fill to capacity.
+	if n := q.PushBackSlice(seq(30, 40)); n != 34 {
+		t.Fatalf("PushBackSlice: %d != 34", n)
+	}
+	checkSeq(t, q, seq(0, 64))
+	if n := q.PushFrontSlice(seq(0, 1)); n != 0 {
+		t.Fatalf("PushFrontSlice to full Q: %d != 0", n)
+	}
+}
+
+func TestBulkPop(t *testing.T) {
+	q := mkWrapped(50)
+	dst := make([]int, 20)
+	if n := q.PeekFrontInto(dst); n != 20 {
+		t.Fatalf("PeekFrontInto: %d != 20", n)
+	}
+	checkSeq(t, queueFrom(dst), seq(0, 20))
+	if n := q.PeekBackInto(dst); n != 20 {
+		t.Fatalf("PeekBackInto: %d != 20", n)
+	}
+	checkSeq(t, queueFrom(dst), seq(30, 20))
+	checkSeq(t, q, seq(0, 50))
+
+	if n := q.PopFrontInto(dst); n != 20 {
+		t.Fatalf("PopFrontInto: %d != 20", n)
+	}
+	checkSeq(t, queueFrom(dst), seq(0, 20))
+	if n := q.PopBackInto(dst[:10]); n != 10 {
+		t.Fatalf("PopBackInto: %d != 10", n)
+	}
+	checkSeq(t, queueFrom(dst[:10]), seq(40, 10))
+	checkSeq(t, q, seq(20, 20))
+	if n := q.PopBackInto(dst[:0]); n != 0 {
+		t.Fatalf("PopBackInto: %d != 0", n)
+	}
+	if n := q.PopFrontInto(make([]int, 30)); n != 20 {
+		t.Fatalf("PopFrontInto: %d != 20", n)
+	}
+	// Check that popped slots are cleared.
+	for i, v := range q.b {
+		if v != 0 {
+			t.Fatalf("Slot %d not cleared: %d", i, v)
+		}
+	}
+}
+
+// queueFrom returns a queue holding the elements of s.
+func queueFrom(s []int) *Queue[int] {
+	q := NewQueue[int](1, 1024)
+	q.PushBackSlice(s)
+	return q
+}
+
+// Compare bulk with per-element operations.
+
+const bulkSz = 1024
+
+func BenchmarkBulk(b *testing.B) {
+	q := NewQueue[int](bulkSz, bulkSz)
+	s := make([]int, bulkSz/4)
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 4; j++ {
+			q.PushBackSlice(s)
+		}
+		for j := 0; j < 4; j++ {
+			q.PopFrontInto(s)
+		}
+	}
+}
+
+func BenchmarkNonBulk(b *testing.B) {
+	q := NewQueue[int](bulkSz, bulkSz)
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < bulkSz; j++ {
+			q.PushBack(j)
+		}
+		for j := 0; j < bulkSz; j++ {
+			q.PopFront()
+		}
+	}
+}
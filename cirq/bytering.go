@@ -0,0 +1,310 @@
+// Copyright (c) 2014, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package cirq
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrUnreadByte = errors.New("Invalid UnreadByte")
+
+// ByteRing is a circular byte buffer. It uses the same design as
+// Queue (a slice with free running indexes, starting at an initial
+// size and growing, by doubling, up to a maximum size, both rounded
+// up to powers of 2), specialized for bytes. Bytes are written at
+// the back (tail) of the ring and read from the front (head).
+//
+// ByteRing implements io.Reader, io.Writer, io.ByteScanner,
+// io.ByteWriter, io.WriterTo and io.ReaderFrom. Writes to a ring
+// that is filled to its maximum capacity fail with ErrFull.
+//
+// ByteRing operations are *NOT* thread safe.
+type ByteRing struct {
+	sz      uint   /* current ring size */
+	maxSz   uint   /* max ring size */
+	m       uint   /* ring mask (sz - 1) */
+	s       uint   /* start index */
+	e       uint   /* end index */
+	b       []byte /* buffer */
+	canUnrd bool   /* last op was a successful ReadByte */
+}
+
+// NewByteRing creates and returns a new byte ring. The ring is
+// initially allocated with space for sz bytes. It can grow, when
+// required, to accomodate up to maxSz bytes. Both sz and maxSz are
+// rounded up to powers of 2. If maxSz is Unbounded, the ring can grow
+// without limit. NewByteRing panics if sz or maxSz are invalid (see
+// NewByteRingSize for a version that returns an error instead).
+func NewByteRing(sz, maxSz int) *ByteRing {
+	br, err := NewByteRingSize(sz, maxSz)
+	if err != nil {
+		panic(err.Error())
+	}
+	return br
+}
+
+// NewByteRingSize is similar to NewByteRing, but instead of panicking,
+// it returns ErrSize if sz or maxSz are invalid (negative, or sz >
+// maxSz).
+func NewByteRingSize(sz, maxSz int) (*ByteRing, error) {
+	usz, umaxSz, err := sizes(sz, maxSz)
+	if err != nil {
+		return nil, err
+	}
+	return &ByteRing{
+		sz: usz, maxSz: umaxSz,
+		m: usz - 1,
+		b: make([]byte, usz),
+	}, nil
+}
+
+// Empty tests if the ring is empty.
+func (br *ByteRing) Empty() bool {
+	return br.s == br.e
+}
+
+// Full tests if the ring is full.
+func (br *ByteRing) Full() bool {
+	return br.e-br.s == br.maxSz
+}
+
+// Len returns the number of bytes waiting in the ring.
+func (br *ByteRing) Len() int {
+	return int(br.e - br.s)
+}
+
+// Cap returns the capacity of the ring (# of bytes currently
+// allocated).
+func (br *ByteRing) Cap() int {
+	return int(br.sz)
+}
+
+// MaxCap returns the maximum capacity of the ring (max # of bytes
+// allowed).
+func (br *ByteRing) MaxCap() int {
+	return int(br.maxSz)
+}
+
+// Reset removes all bytes from the ring. It does not change its
+// capacity.
+func (br *ByteRing) Reset() {
+	br.s, br.e = 0, 0
+	br.canUnrd = false
+}
+
+// Bytes returns the bytes waiting in the ring, without removing
+// them, as two slices aliasing the ring's buffer: a holds the bytes
+// up to the end of the buffer and b the bytes that wrap around to its
+// start (b is empty if the contents do not wrap around). The slices
+// are suitable for vectored writes (e.g. as net.Buffers). They are
+// valid only until the next ring operation that adds or removes
+// bytes. Use Discard to remove bytes from the ring after consuming
+// them.
+func (br *ByteRing) Bytes() (a, b []byte) {
+	si, ei := br.s&br.m, br.e&br.m
+	if si < ei || br.s == br.e {
+		return br.b[si:ei], br.b[:0]
+	}
+	return br.b[si:], br.b[:ei]
+}
+
+// Free returns the free space of the ring, without growing it, as two
+// slices aliasing the ring's buffer: a holds the free space up to the
+// end of the buffer and b the free space that wraps around to its
+// start. The slices are suitable for vectored reads. Use Commit to
+// add the bytes stored in them to the ring.
+func (br *ByteRing) Free() (a, b []byte) {
+	si, ei := br.s&br.m, br.e&br.m
+	if ei < si || br.e-br.s == br.sz {
+		return br.b[ei:si], br.b[:0]
+	}
+	return br.b[ei:], br.b[:si]
+}
+
+// Discard removes up to n bytes from the front of the ring. Returns
+// the number of bytes removed.
+func (br *ByteRing) Discard(n int) int {
+	br.canUnrd = false
+	if l := int(br.e - br.s); n > l {
+		n = l
+	}
+	if n < 0 {
+		n = 0
+	}
+	br.s += uint(n)
+	return n
+}
+
+// Commit adds n bytes, previously stored in the slices returned by
+// Free, to the back of the ring. Panics if n is larger than the free
+// space returned by Free.
+func (br *ByteRing) Commit(n int) {
+	br.canUnrd = false
+	if n < 0 || n > int(br.sz-(br.e-br.s)) {
+		panic("Commit to ring out of range")
+	}
+	br.e += uint(n)
+}
+
+// Grow grows the ring's capacity, if necessary, to guarantee space for
+// another n bytes, but not beyond the maximum capacity. Returns the
+// free space available after growing.
+func (br *ByteRing) Grow(n int) int {
+	l := br.e - br.s
+	if n > int(br.maxSz-l) {
+		n = int(br.maxSz - l)
+	}
+	if l+uint(n) > br.sz {
+		br.resize(roundUp2(l + uint(n)))
+	}
+	return int(br.sz - l)
+}
+
+// Read reads up to len(p) bytes from the front of the ring into
+// p. Returns the number of bytes read. If the ring is empty and
+// len(p) > 0, Read returns io.EOF.
+func (br *ByteRing) Read(p []byte) (n int, err error) {
+	br.canUnrd = false
+	if br.s == br.e {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	a, b := br.Bytes()
+	n = copy(p, a)
+	n += copy(p[n:], b)
+	br.s += uint(n)
+	return n, nil
+}
+
+// ReadByte reads and returns the front byte of the ring. If the ring
+// is empty, ReadByte returns io.EOF.
+func (br *ByteRing) ReadByte() (byte, error) {
+	if br.s == br.e {
+		br.canUnrd = false
+		return 0, io.EOF
+	}
+	c := br.b[br.s&br.m]
+	br.s++
+	br.canUnrd = true
+	return c, nil
+}
+
+// UnreadByte un-reads the last byte read. Only the byte returned by
+// the most recent successful ReadByte can be un-read; if any other
+// ring operation has intervened, UnreadByte returns ErrUnreadByte.
+func (br *ByteRing) UnreadByte() error {
+	if !br.canUnrd {
+		return ErrUnreadByte
+	}
+	br.canUnrd = false
+	br.s--
+	return nil
+}
+
+// Write writes the bytes of p to the back of the ring, growing it as
+// required. If the ring cannot accomodate all of p, Write stores as
+// many bytes as possible and returns ErrFull. Returns the number of
+// bytes written.
+func (br *ByteRing) Write(p []byte) (n int, err error) {
+	br.canUnrd = false
+	br.Grow(len(p))
+	a, b := br.Free()
+	n = copy(a, p)
+	n += copy(b, p[n:])
+	br.e += uint(n)
+	if n < len(p) {
+		return n, ErrFull
+	}
+	return n, nil
+}
+
+// WriteByte writes byte c to the back of the ring. Returns ErrFull if
+// the ring is full.
+func (br *ByteRing) WriteByte(c byte) error {
+	br.canUnrd = false
+	if br.e-br.s == br.sz {
+		if br.sz == br.maxSz {
+			return ErrFull
+		}
+		br.resize(br.sz << 1)
+	}
+	br.b[br.e&br.m] = c
+	br.e++
+	return nil
+}
+
+// WriteTo writes the bytes of the ring to w, until the ring is empty
+// or an error occurs. Bytes written are removed from the ring. Returns
+// the number of bytes written, and any error encountered.
+func (br *ByteRing) WriteTo(w io.Writer) (n int64, err error) {
+	br.canUnrd = false
+	for br.s != br.e {
+		a, _ := br.Bytes()
+		m, err := w.Write(a)
+		if m > len(a) {
+			panic("ByteRing.WriteTo: invalid Write count")
+		}
+		br.s += uint(m)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+		if m != len(a) {
+			return n, io.ErrShortWrite
+		}
+	}
+	return n, nil
+}
+
+// minRead is the minimum free space ReadFrom tries to provide to each
+// Read call.
+const minRead = 512
+
+// ReadFrom reads data from r, until io.EOF or an error, and writes it
+// to the back of the ring, growing it as required. Returns the number
+// of bytes read and any error encountered except io.EOF. If the ring
+// becomes full before io.EOF is reached, ReadFrom returns ErrFull.
+func (br *ByteRing) ReadFrom(r io.Reader) (n int64, err error) {
+	br.canUnrd = false
+	for {
+		if br.Grow(minRead) == 0 {
+			return n, ErrFull
+		}
+		a, b := br.Free()
+		if len(a) == 0 {
+			a = b
+		}
+		m, err := r.Read(a)
+		if m < 0 || m > len(a) {
+			panic("ByteRing.ReadFrom: invalid Read count")
+		}
+		br.e += uint(m)
+		n += int64(m)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// resize, resizes the ring to size sz. The caller *must* make sure
+// than sz satisfies all three: (1) sz >= br.Len(), (2) sz is a power
+// of 2, (3) sz <= br.maxSz
+func (br *ByteRing) resize(sz uint) {
+	b := make([]byte, 0, sz)
+	x, y := br.Bytes()
+	b = append(b, x...)
+	b = append(b, y...)
+	br.b = b[:sz]
+	br.s, br.e = 0, br.e-br.s
+	br.sz = sz
+	br.m = sz - 1
+}
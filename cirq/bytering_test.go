@@ -0,0 +1,135 @@
+package cirq
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func pattern(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+	return b
+}
+
+func TestByteRingReadWrite(t *testing.T) {
+	br := NewByteRing(4, 64)
+	p := pattern(1000)
+	var out []byte
+	buf := make([]byte, 13)
+	for i := 0; i < len(p); i += 37 {
+		end := min(i+37, len(p))
+		if n, err := br.Write(p[i:end]); err != nil || n != end-i {
+			t.Fatalf("Write: %d, %v", n, err)
+		}
+		for br.Len() > 20 {
+			n, err := br.Read(buf)
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			out = append(out, buf[:n]...)
+		}
+	}
+	for {
+		n, err := br.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		out = append(out, buf[:n]...)
+	}
+	if !bytes.Equal(out, p) {
+		t.Fatal("Bad data read")
+	}
+	if br.Cap() != 64 {
+		t.Fatalf("Ring cap %d != 64", br.Cap())
+	}
+}
+
+func TestByteRingFull(t *testing.T) {
+	br := NewByteRing(4, 16)
+	n, err := br.Write(pattern(20))
+	if n != 16 || err != ErrFull || !br.Full() {
+		t.Fatalf("Write to full ring: %d, %v", n, err)
+	}
+	if err := br.WriteByte(0); err != ErrFull {
+		t.Fatalf("WriteByte to full ring: %v", err)
+	}
+	a, b := br.Bytes()
+	if len(a)+len(b) != 16 {
+		t.Fatalf("Bad regions: %d + %d", len(a), len(b))
+	}
+	if a, b := br.Free(); len(a)+len(b) != 0 {
+		t.Fatalf("Bad free regions: %d + %d", len(a), len(b))
+	}
+}
+
+func TestByteRingScan(t *testing.T) {
+	br := NewByteRing(1, 8)
+	for _, c := range []byte("abc") {
+		br.WriteByte(c)
+	}
+	c, _ := br.ReadByte()
+	if c != 'a' {
+		t.Fatalf("ReadByte %q != 'a'", c)
+	}
+	if err := br.UnreadByte(); err != nil {
+		t.Fatalf("UnreadByte: %v", err)
+	}
+	if err := br.UnreadByte(); err != ErrUnreadByte {
+		t.Fatalf("Second UnreadByte: %v", err)
+	}
+	if err := iotest.TestReader(br, []byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestByteRingRegions(t *testing.T) {
+	br := NewByteRing(16, 16)
+	br.Write(pattern(12))
+	br.Discard(8)
+	br.Write(pattern(10)) // wraps around
+	a, b := br.Bytes()
+	if len(a) != 8 || len(b) != 6 {
+		t.Fatalf("Bad regions: %d + %d", len(a), len(b))
+	}
+	if !bytes.Equal(append(a, b...), append(pattern(12)[8:], pattern(10)...)) {
+		t.Fatal("Bad region data")
+	}
+	fa, fb := br.Free()
+	if len(fa) != 2 || len(fb) != 0 {
+		t.Fatalf("Bad free regions: %d + %d", len(fa), len(fb))
+	}
+	copy(fa, "xy")
+	br.Commit(2)
+	if !br.Full() {
+		t.Fatal("Ring not full after commit")
+	}
+	if n := br.Discard(100); n != 16 || !br.Empty() {
+		t.Fatalf("Discard: %d", n)
+	}
+}
+
+func TestByteRingCopy(t *testing.T) {
+	p := pattern(5000)
+	br := NewByteRing(8, 8192)
+	n, err := br.ReadFrom(iotest.OneByteReader(bytes.NewReader(p)))
+	if err != nil || n != int64(len(p)) {
+		t.Fatalf("ReadFrom: %d, %v", n, err)
+	}
+	var w bytes.Buffer
+	n, err = br.WriteTo(&w)
+	if err != nil || n != int64(len(p)) || !br.Empty() {
+		t.Fatalf("WriteTo: %d, %v", n, err)
+	}
+	if !bytes.Equal(w.Bytes(), p) {
+		t.Fatal("Bad data copied")
+	}
+	br = NewByteRing(8, 1024)
+	if n, err = br.ReadFrom(bytes.NewReader(p)); err != ErrFull ||
+		n != 1024 {
+		t.Fatalf("ReadFrom to full ring: %d, %v", n, err)
+	}
+}
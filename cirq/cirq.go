@@ -1,5 +1,3 @@
-// Auto-generated. !! DO NOT EDIT !!
-
 // Copyright (c) 2014, Nick Patavalis (npat@efault.net).
 // All rights reserved.
 // Use of this source code is governed by a BSD-style license that can
@@ -7,74 +5,199 @@
 
 package cirq
 
-// CQ is a circular queue.
+import (
+	"errors"
+	"math/bits"
+)
+
+var (
+	ErrSize    = errors.New("Invalid Q size")
+	ErrOptions = errors.New("Invalid Q options")
+)
+
+// Unbounded can be given as the maximum size of a queue, to allow it
+// to grow without limit (other than the available memory).
+const Unbounded = -1
+
+// maxCap is the largest queue size supported (the largest power of 2
+// that fits in an int).
+const maxCap = 1 << (bits.UintSize - 2)
+
+// Queue is a circular queue holding elements of type T.
 //
 // It is implemented with a slice and free running indexes. It starts
-// with a user specified initial size (which must be a power of 2) and
+// with a user specified initial size (rounded up to a power of 2) and
 // grows exponentially (doubles in size), when required, to accomodate
-// more elements (up to a user specified maximum size).
+// more elements (up to a user specified maximum size, also rounded up
+// to a power of 2). Optionally, it can also shrink automatically (see
+// Options). Indexes are of type uint (64 bits wide on 64-bit
+// platforms).
 //
 // Queue operations are *NOT* thread safe.
-type CQ struct {
-	sz    uint32        /* current queue size */
-	maxSz uint32        /* max queue size */
-	m     uint32        /* queue mask (sz - 1) */
-	s     uint32        /* start index */
-	e     uint32        /* end index */
-	b     []interface{} /* buffer */
+type Queue[T any] struct {
+	sz    uint   /* current queue size */
+	maxSz uint   /* max queue size */
+	m     uint   /* queue mask (sz - 1) */
+	s     uint   /* start index */
+	e     uint   /* end index */
+	mod   uint   /* modification count (see iterators) */
+	ovr   bool   /* overwrite mode */
+	drops uint64 /* # of elements dropped in overwrite mode */
+	shr   bool   /* automatic shrinking enabled */
+	shDiv uint   /* shrink when len < sz / shDiv */
+	minSz uint   /* don't shrink below this size */
+	b     []T    /* buffer */
 }
 
-// New creates and returns a new circular queue.
+// CQ is a circular queue holding elements of type interface{}. It is
+// kept for compatibility with code written before Queue became
+// generic. New code should use Queue directly.
+type CQ = Queue[interface{}]
+
+// Options are optional queue parameters, given when the queue is
+// created (see NewQueueOpts).
+type Options struct {
+	// Overwrite enables the queue's overwrite mode (see
+	// Queue.SetOverwrite).
+	Overwrite bool
+	// Shrink enables automatic shrinking. If enabled, the queue
+	// is shrunk (its size is halved) whenever elements are
+	// removed and the number of remaining ones drops below
+	// Cap/ShrinkDiv. Since the queue grows only when it is full,
+	// this provides hysteresis between growing and shrinking.
+	// For PopFront and PopBack (which are kept small enough to be
+	// inlined) shrinking is deferred, and it is performed by the
+	// next operation that depends on, or changes, the queue size
+	// (e.g. Cap or PushBack).
+	Shrink bool
+	// ShrinkDiv is the divisor for the shrinking threshold (see
+	// above). If zero, 4 is used. Otherwise it must be at least
+	// 3 (to provide hysteresis).
+	ShrinkDiv int
+	// MinCap is the size below which the queue is never shrunk
+	// automatically. If zero, the initial queue size is
+	// used. Otherwise it is rounded up to a power of 2, and it
+	// must not be larger than the maximum queue size.
+	MinCap int
+}
+
+// NewQueue creates and returns a new circular queue holding elements
+// of type T.
 //
 // The queue is initially allocated with space for sz elements. It can
 // grow, when required, to accomodate up to maxSz elements. Both sz
-// and maxSz *must* be powers of 2.
+// and maxSz are rounded up to powers of 2. If maxSz is Unbounded, the
+// queue can grow without limit. NewQueue panics if sz or maxSz are
+// invalid (see NewQueueOpts for a version that returns an error
+// instead).
+func NewQueue[T any](sz, maxSz int) *Queue[T] {
+	cq, err := NewQueueOpts[T](sz, maxSz, nil)
+	if err != nil {
+		panic(err.Error())
+	}
+	return cq
+}
+
+// NewQueueOpts is similar to NewQueue, but also accepts optional
+// queue parameters (opts may be nil). Instead of panicking, it returns
+// ErrSize if sz or maxSz are invalid (negative, or sz > maxSz), and
+// ErrOptions if opts are invalid.
+func NewQueueOpts[T any](sz, maxSz int, opts *Options) (*Queue[T], error) {
+	usz, umaxSz, err := sizes(sz, maxSz)
+	if err != nil {
+		return nil, err
+	}
+	cq := &Queue[T]{
+		sz: usz, maxSz: umaxSz,
+		m: usz - 1,
+		s: 0, e: 0,
+	}
+	if opts != nil {
+		if err := cq.setOpts(opts); err != nil {
+			return nil, err
+		}
+	}
+	cq.b = make([]T, usz)
+	return cq, nil
+}
+
+// sizes validates the initial (sz) and maximum (maxSz) sizes of a
+// queue and rounds them up to powers of 2.
+func sizes(sz, maxSz int) (usz, umaxSz uint, err error) {
+	if maxSz == Unbounded {
+		maxSz = maxCap
+	}
+	if sz < 0 || maxSz <= 0 || maxSz > maxCap || sz > maxSz {
+		return 0, 0, ErrSize
+	}
+	return roundUp2(uint(sz)), roundUp2(uint(maxSz)), nil
+}
+
+// setOpts validates and applies opts to the queue.
+func (cq *Queue[T]) setOpts(opts *Options) error {
+	shDiv, minSz := opts.ShrinkDiv, opts.MinCap
+	if shDiv == 0 {
+		shDiv = 4
+	}
+	if minSz == 0 {
+		minSz = int(cq.sz)
+	}
+	if shDiv < 3 || minSz < 0 || minSz > int(cq.maxSz) {
+		return ErrOptions
+	}
+	cq.ovr = opts.Overwrite
+	cq.shr = opts.Shrink
+	cq.shDiv, cq.minSz = uint(shDiv), roundUp2(uint(minSz))
+	return nil
+}
+
+// New creates and returns a new circular queue holding elements of
+// type interface{}. Unlike NewQueue, it requires both sz and maxSz to
+// be powers of 2, and panics if they are not. Otherwise it is
+// equivalent to NewQueue[interface{}].
 func New(sz, maxSz int) *CQ {
-	if sz <= 0 || uint32(sz)&(uint32(sz)-1) != 0 ||
-		uint32(maxSz)&(uint32(maxSz)-1) != 0 ||
+	if sz <= 0 || uint(sz)&(uint(sz)-1) != 0 ||
+		uint(maxSz)&(uint(maxSz)-1) != 0 ||
 		maxSz < sz {
 		panic("Invalid Q size")
 	}
-	cq := &CQ{
-		sz: uint32(sz), maxSz: uint32(maxSz),
-		m: uint32(sz) - 1,
-		s: 0, e: 0,
-	}
-	cq.b = make([]interface{}, sz)
-	return cq
+	return NewQueue[interface{}](sz, maxSz)
 }
 
 // Empty tests if the queue is empty.
-func (cq *CQ) Empty() bool {
+func (cq *Queue[T]) Empty() bool {
 	return cq.s == cq.e
 }
 
 // Full tests if the queue is full.
-func (cq *CQ) Full() bool {
+func (cq *Queue[T]) Full() bool {
 	return cq.e-cq.s == cq.maxSz
 }
 
 // Len returns the number of elements waiting in the queue.
-func (cq *CQ) Len() int {
+func (cq *Queue[T]) Len() int {
 	return int(cq.e - cq.s)
 }
 
 // Cap returns the capacity of the queue (# of element slots currently
 // allocated).
-func (cq *CQ) Cap() int {
+func (cq *Queue[T]) Cap() int {
+	if cq.shr {
+		cq.shrink()
+	}
 	return int(cq.sz)
 }
 
 // MaxCap returns the maximum capacity of the queue (max # of element
 // allowed).
-func (cq *CQ) MaxCap() int {
+func (cq *Queue[T]) MaxCap() int {
 	return int(cq.maxSz)
 }
 
 // PeekFront returns the front (head) element of the queue, without
 // removing it. Returns ok == false if the list is empty (unable to
 // peek element), ok == true otherwise.
-func (cq *CQ) PeekFront() (el interface{}, ok bool) {
+func (cq *Queue[T]) PeekFront() (el T, ok bool) {
 	if cq.s == cq.e {
 		return el, false
 	}
@@ -83,7 +206,7 @@ func (cq *CQ) PeekFront() (el interface{}, ok bool) {
 
 // MustPeekFront returns the front (head) element of the queue, without
 // removing it. Panics if the queue is empty.
-func (cq *CQ) MustPeekFront() (el interface{}) {
+func (cq *Queue[T]) MustPeekFront() (el T) {
 	if cq.s == cq.e {
 		panic("MustPeekFront from empty Q")
 	}
@@ -93,7 +216,7 @@ func (cq *CQ) MustPeekFront() (el interface{}) {
 // PeekBack returns the back (tail) element of the queue, without
 // removing it. Returns ok == false if the list is empty (unable to
 // peek element), ok == true otherwise.
-func (cq *CQ) PeekBack() (el interface{}, ok bool) {
+func (cq *Queue[T]) PeekBack() (el T, ok bool) {
 	if cq.s == cq.e {
 		return el, false
 	}
@@ -102,7 +225,7 @@ func (cq *CQ) PeekBack() (el interface{}, ok bool) {
 
 // MustPeekBack returns the back (tail) element of the queue, without
 // removing it. Panics if the queue is empty.
-func (cq *CQ) MustPeekBack() (el interface{}) {
+func (cq *Queue[T]) MustPeekBack() (el T) {
 	if cq.s == cq.e {
 		panic("MustPeekBack from empty Q")
 	}
@@ -112,26 +235,28 @@ func (cq *CQ) MustPeekBack() (el interface{}) {
 // PopFront removes the front (head) element from the queue and returns
 // it. Returns ok == false if the list was empty (unable to pop
 // element), ok == true otherwise.
-func (cq *CQ) PopFront() (el interface{}, ok bool) {
-	var zero interface{}
+func (cq *Queue[T]) PopFront() (el T, ok bool) {
+	var zero T
 	if cq.s == cq.e {
 		return zero, false
 	}
 	el = cq.b[cq.s&cq.m]
 	cq.b[cq.s&cq.m] = zero
 	cq.s++
+	cq.mod++
 	return el, true
 }
 
 // PopBack removes the back (tail) element from the queue and returns
 // it. Returns ok == false if the list was empty (unable to pop
 // elemnt), ok == true otherwise.
-func (cq *CQ) PopBack() (el interface{}, ok bool) {
-	var zero interface{}
+func (cq *Queue[T]) PopBack() (el T, ok bool) {
+	var zero T
 	if cq.s == cq.e {
 		return zero, false
 	}
 	cq.e--
+	cq.mod++
 	el = cq.b[cq.e&cq.m]
 	cq.b[cq.e&cq.m] = zero
 	return el, true
@@ -139,37 +264,150 @@ func (cq *CQ) PopBack() (el interface{}, ok bool) {
 
 // PushBack adds element "el" to the back (tail) of the queue. Returns
 // ok == false if the list was full (unable to push element), ok ==
-// true otherwise.
-func (cq *CQ) PushBack(el interface{}) (ok bool) {
+// true otherwise. In overwrite mode (see SetOverwrite), if the list
+// is full, the front element is dropped to make room for "el", and
+// PushBack always returns ok == true.
+func (cq *Queue[T]) PushBack(el T) (ok bool) {
+	if cq.shr {
+		cq.shrink()
+	}
 	if cq.e-cq.s == cq.sz {
 		if cq.sz == cq.maxSz {
-			return false
+			if !cq.ovr {
+				return false
+			}
+			cq.dropFront()
+		} else {
+			cq.resize(cq.sz << 1)
 		}
-		cq.resize(cq.sz << 1)
 	}
 	cq.b[cq.e&cq.m] = el
 	cq.e++
+	cq.mod++
 	return true
 }
 
 // PushFront adds element "e" to the front (head) of the queue. Returns
 // ok == false if the list was full (unable to push element), ok ==
-// true otherwise.
-func (cq *CQ) PushFront(el interface{}) (ok bool) {
+// true otherwise. In overwrite mode (see SetOverwrite), if the list
+// is full, the back element is dropped to make room for "el", and
+// PushFront always returns ok == true.
+func (cq *Queue[T]) PushFront(el T) (ok bool) {
+	if cq.shr {
+		cq.shrink()
+	}
 	if cq.e-cq.s == cq.sz {
 		if cq.sz == cq.maxSz {
-			return false
+			if !cq.ovr {
+				return false
+			}
+			cq.dropBack()
+		} else {
+			cq.resize(cq.sz << 1)
 		}
-		cq.resize(cq.sz << 1)
 	}
 	cq.s--
+	cq.mod++
 	cq.b[cq.s&cq.m] = el
 	return true
 }
 
+// At returns the i'th element of the queue, counting from the front
+// (head) of the queue (the front element has index 0, the back
+// element has index Len()-1). Panics if i is out of range.
+func (cq *Queue[T]) At(i int) (el T) {
+	if i < 0 || i >= int(cq.e-cq.s) {
+		panic("At Q index out of range")
+	}
+	return cq.b[(cq.s+uint(i))&cq.m]
+}
+
+// Set replaces the i'th element of the queue, counting from the front
+// (head) of the queue, with element "el". Panics if i is out of
+// range.
+func (cq *Queue[T]) Set(i int, el T) {
+	if i < 0 || i >= int(cq.e-cq.s) {
+		panic("Set Q index out of range")
+	}
+	cq.b[(cq.s+uint(i))&cq.m] = el
+}
+
+// InsertAt inserts element "el" in the queue, at position i, counting
+// from the front (head) of the queue. After the insertion, "el" has
+// index i. The elements between the insertion point and the nearest
+// end of the queue are shifted by one position, towards that end. It
+// is ok for i to be equal to Len(), in which case InsertAt is
+// equivalent to PushBack. Returns ok == false if the list was full
+// (unable to insert element), ok == true otherwise. Panics if i is
+// out of range.
+func (cq *Queue[T]) InsertAt(i int, el T) (ok bool) {
+	if cq.shr {
+		cq.shrink()
+	}
+	n := cq.e - cq.s
+	if i < 0 || i > int(n) {
+		panic("InsertAt Q index out of range")
+	}
+	if n == cq.sz {
+		if cq.sz == cq.maxSz {
+			return false
+		}
+		cq.resize(cq.sz << 1)
+	}
+	j := uint(i)
+	if j < n/2 {
+		cq.s--
+		cq.mod++
+		for k := cq.s; k != cq.s+j; k++ {
+			cq.b[k&cq.m] = cq.b[(k+1)&cq.m]
+		}
+	} else {
+		for k := cq.e; k != cq.s+j; k-- {
+			cq.b[k&cq.m] = cq.b[(k-1)&cq.m]
+		}
+		cq.e++
+		cq.mod++
+	}
+	cq.b[(cq.s+j)&cq.m] = el
+	return true
+}
+
+// RemoveAt removes the i'th element from the queue, counting from the
+// front (head) of the queue, and returns it. The elements between the
+// removed one and the nearest end of the queue are shifted by one
+// position, to fill the gap. Panics if i is out of range.
+func (cq *Queue[T]) RemoveAt(i int) (el T) {
+	var zero T
+	n := cq.e - cq.s
+	if i < 0 || i >= int(n) {
+		panic("RemoveAt Q index out of range")
+	}
+	j := uint(i)
+	el = cq.b[(cq.s+j)&cq.m]
+	if j < n/2 {
+		for k := cq.s + j; k != cq.s; k-- {
+			cq.b[k&cq.m] = cq.b[(k-1)&cq.m]
+		}
+		cq.b[cq.s&cq.m] = zero
+		cq.s++
+		cq.mod++
+	} else {
+		for k := cq.s + j; k != cq.e-1; k++ {
+			cq.b[k&cq.m] = cq.b[(k+1)&cq.m]
+		}
+		cq.e--
+		cq.mod++
+		cq.b[cq.e&cq.m] = zero
+	}
+	if cq.shr {
+		cq.shrink()
+	}
+	return el
+}
+
 // roundUp2 rounds v up to the nearest power of 2
 // see: http://graphics.stanford.edu/~seander/bithacks.html#RoundUpPowerOf2
-func roundUp2(v uint32) uint32 {
+func roundUp2(v uint) uint {
 	if v == 0 {
 		return 1
 	}
@@ -179,24 +417,29 @@ func roundUp2(v uint32) uint32 {
 	v |= v >> 4
 	v |= v >> 8
 	v |= v >> 16
+	v |= v >> (bits.UintSize / 2) // 32, on 64-bit platforms
 	v++
 	return v
 }
 
 // Compact resizes the queue slice (without removing elements from the
-// queue) to the smallest possible size, but not smaller than
-// sz. Argument sz *must* be a power of 2. In effect, Compact changes
-// the current size of the queue slice to the smalest possible size
-// nSz that satisfies all three: (1) nSz is a power of 2, (2) nSz >=
-// cq.Len(), (3) nSz >= sz. Compact does not affect the maximum
+// queue) to the smallest possible size, but not smaller than sz. In
+// effect, Compact changes the current size of the queue slice to the
+// smalest possible size nSz that satisfies all three: (1) nSz is a
+// power of 2, (2) nSz >= cq.Len(), (3) nSz >= sz. Values of sz larger
+// than the maximum capacity of the queue are treated as equal to it,
+// and negative ones as zero. Compact does not affect the maximum
 // capacity (maxSz) of the queue.
-func (cq *CQ) Compact(sz int) {
-	if sz < 0 || uint32(sz) > cq.maxSz || uint32(sz)&(uint32(sz-1)) != 0 {
-		panic("Compact Q with invalid size")
+func (cq *Queue[T]) Compact(sz int) {
+	if sz < 0 {
+		sz = 0
+	}
+	if sz > int(cq.maxSz) {
+		sz = int(cq.maxSz)
 	}
 	nSz := roundUp2(cq.e - cq.s)
-	if nSz < uint32(sz) {
-		nSz = uint32(sz)
+	if rSz := roundUp2(uint(sz)); nSz < rSz {
+		nSz = rSz
 	}
 	if nSz == cq.sz {
 		return
@@ -204,20 +447,27 @@ func (cq *CQ) Compact(sz int) {
 	cq.resize(nSz)
 }
 
+// shrink halves the queue size, repeatedly, while the number of
+// elements is below the shrinking threshold (see Options), and the
+// size is larger than the minimum.
+func (cq *Queue[T]) shrink() {
+	nSz := cq.sz
+	for nSz > cq.minSz && cq.e-cq.s < nSz/cq.shDiv {
+		nSz >>= 1
+	}
+	if nSz != cq.sz {
+		cq.resize(nSz)
+	}
+}
+
 // resize, resizes the queue to size sz. The caller *must* make sure
 // than sz satisfies all three: (1) sz >= cq.Len(), (2) sz is a power
 // of 2, (3) sz <= cq.maxSz
-func (cq *CQ) resize(sz uint32) {
-	b := make([]interface{}, 0, sz)
-	si, ei := cq.s&cq.m, cq.e&cq.m
-	if si < ei {
-		b = append(b, cq.b[si:ei]...)
-	} else {
-		b = append(b, cq.b[si:]...)
-		b = append(b, cq.b[:ei]...)
-	}
+func (cq *Queue[T]) resize(sz uint) {
+	b := cq.appendElems(make([]T, 0, sz))
 	cq.b = b[:sz]
 	cq.s, cq.e = 0, cq.e-cq.s
+	cq.mod++
 	cq.sz = sz
 	cq.m = sz - 1
 }
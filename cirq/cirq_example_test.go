@@ -46,3 +46,19 @@ func Example() {
 	// 3
 	// 0 16
 }
+
+func ExampleQueue() {
+	// Create a queue of strings with initial size 2 and capacity
+	// 8. No type assertions are required when removing elements.
+	q := cirq.NewQueue[string](2, 8)
+	q.PushBack("b")
+	q.PushBack("c")
+	q.PushFront("a")
+	for !q.Empty() {
+		s, _ := q.PopFront()
+		fmt.Print(s)
+	}
+	fmt.Println()
+	// Output:
+	// abc
+}
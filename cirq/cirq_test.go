@@ -220,3 +220,139 @@ func TestMustPeek(t *testing.T) {
 		q.MustPeekFront()
 	}()
 }
+
+// checkSeq checks that the elements of q are equal to those of s.
+func checkSeq(t *testing.T, q *Queue[int], s []int) {
+	t.Helper()
+	if q.Len() != len(s) {
+		t.Fatalf("Q len %d != %d", q.Len(), len(s))
+	}
+	for i := range s {
+		if q.At(i) != s[i] {
+			t.Fatalf("Element %d: %d != %d", i, q.At(i), s[i])
+		}
+	}
+}
+
+func TestAtSet(t *testing.T) {
+	maxSz := 64
+	q := NewQueue[int](1, maxSz)
+	s := []int{}
+	for i := 0; i < maxSz/2; i++ {
+		// Make the queue wrap around
+		q.PushFront(-i)
+		s = append([]int{-i}, s...)
+		q.PushBack(i)
+		s = append(s, i)
+	}
+	checkSeq(t, q, s)
+	for i := range s {
+		s[i] *= 10
+		q.Set(i, s[i])
+	}
+	checkSeq(t, q, s)
+	for _, i := range []int{-1, q.Len()} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("No panic for At(%d)", i)
+				}
+			}()
+			q.At(i)
+		}()
+	}
+}
+
+func TestInsertRemoveAt(t *testing.T) {
+	maxSz := 64
+	q := NewQueue[int](1, maxSz)
+	s := []int{}
+	// Insert at every possible position, so that both the front
+	// and the back parts are shifted.
+	for i := 0; i < maxSz; i++ {
+		j := (i * 7) % (len(s) + 1)
+		if !q.InsertAt(j, i) {
+			t.Fatalf("Cannot insert %d at %d", i, j)
+		}
+		s = append(s[:j], append([]int{i}, s[j:]...)...)
+		checkSeq(t, q, s)
+	}
+	if q.InsertAt(0, 0) {
+		t.Fatal("Insert to full Q")
+	}
+	for len(s) > 0 {
+		j := len(s) / 3
+		if el := q.RemoveAt(j); el != s[j] {
+			t.Fatalf("Removed element %d != %d", el, s[j])
+		}
+		s = append(s[:j], s[j+1:]...)
+		checkSeq(t, q, s)
+		if len(s) > 1 {
+			j = len(s) - 1 - len(s)/3
+			if el := q.RemoveAt(j); el != s[j] {
+				t.Fatalf("Removed element %d != %d", el, s[j])
+			}
+			s = append(s[:j], s[j+1:]...)
+			checkSeq(t, q, s)
+		}
+	}
+	if !q.Empty() {
+		t.Fatal("Q not empty")
+	}
+}
+
+func TestSizes(t *testing.T) {
+	for _, c := range []struct{ sz, maxSz, rSz, rMaxSz int }{
+		{0, 1, 1, 1},
+		{3, 5, 4, 8},
+		{5, 5, 8, 8},
+		{100, 1000, 128, 1024},
+		{1, Unbounded, 1, maxCap},
+	} {
+		q, err := NewQueueOpts[int](c.sz, c.maxSz, nil)
+		if err != nil {
+			t.Fatalf("Sizes %d, %d: %v", c.sz, c.maxSz, err)
+		}
+		if q.Cap() != c.rSz || q.MaxCap() != c.rMaxSz {
+			t.Fatalf("Sizes %d, %d: %d, %d != %d, %d",
+				c.sz, c.maxSz, q.Cap(), q.MaxCap(), c.rSz, c.rMaxSz)
+		}
+	}
+	for _, c := range []struct{ sz, maxSz int }{
+		{-1, 1}, {0, 0}, {5, 4}, {1, -2}, {1, maxCap + 1},
+	} {
+		if _, err := NewQueueOpts[int](c.sz, c.maxSz, nil); err != ErrSize {
+			t.Fatalf("Sizes %d, %d: %v != %v", c.sz, c.maxSz, err, ErrSize)
+		}
+		if _, err := NewByteRingSize(c.sz, c.maxSz); err != ErrSize {
+			t.Fatalf("Ring sizes %d, %d: %v != %v",
+				c.sz, c.maxSz, err, ErrSize)
+		}
+	}
+}
+
+func TestUnbounded(t *testing.T) {
+	q := NewQueue[int](0, Unbounded)
+	q.PushBackSlice(seq(0, 100000))
+	if q.Full() || q.Len() != 100000 {
+		t.Fatalf("Bad unbounded Q: F=%v, L=%d", q.Full(), q.Len())
+	}
+	q.Compact(-1)
+	if q.Cap() != 131072 {
+		t.Fatalf("Q cap %d != 131072", q.Cap())
+	}
+}
+
+func TestIndexWrap(t *testing.T) {
+	// Free running indexes wrap around the end of uint.
+	q := NewQueue[int](4, 4)
+	q.s, q.e = ^uint(0)-1, ^uint(0)-1
+	for i := 0; i < 10; i++ {
+		q.PushBack(i)
+		q.PushBack(i + 1)
+		if v, _ := q.PopFront(); v != i {
+			t.Fatalf("Bad element %d != %d", v, i)
+		}
+		q.PopFront()
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright (c) 2014, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package cirq
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/npat-efault/gohacks/pq"
+	"github.com/npat-efault/gohacks/task"
+)
+
+// DelayHandle identifies an element pushed to a DelayQueue. It can be
+// used to reschedule or cancel the element, before it becomes due.
+type DelayHandle[T any] struct {
+	v  T
+	at time.Time                   /* time the element becomes due */
+	hd *pq.Handle[*DelayHandle[T]] /* handle in the pending heap */
+}
+
+// Value returns the value of the element identified by the handle.
+func (h *DelayHandle[T]) Value() T {
+	return h.v
+}
+
+// DelayQueue is a thread-safe queue where each element carries a
+// ready (due) time. Elements are popped in the order they become due,
+// and Pop blocks until the earliest element is due. Elements that
+// have not yet become due can be rescheduled or canceled, using the
+// handles returned when they were pushed.
+//
+// Pending elements are kept in a heap (see package pq), ordered by
+// their due times. A single timer goroutine moves elements, as they
+// become due, from the heap to a FIFO Queue, from which they are
+// popped. The timer goroutine is run as a task (see package task), so
+// DelayQueue implements task.Task: Kill stops the timer goroutine and
+// closes the queue, and Wait waits for the goroutine to exit. Once
+// killed, pushes fail with ErrClosed, while pops succeed until the
+// due elements are drained, and fail with ErrClosed afterwards. Any
+// elements that were still pending are discarded.
+//
+// All DelayQueue methods can be called concurrently from multiple
+// goroutines.
+type DelayQueue[T any] struct {
+	m      sync.Mutex
+	pend   *pq.Heap[*DelayHandle[T]] /* pending elements */
+	rdy    *Queue[T]                 /* due elements */
+	closed bool
+	nPopW  int           /* # of goroutines waiting to pop */
+	notEmp chan struct{} /* closed when an element becomes due */
+	kick   chan struct{} /* wakes the timer goroutine */
+	t      *task.Single  /* the timer goroutine */
+}
+
+// NewDelayQueue creates and returns a new delay queue, and starts its
+// timer goroutine. The queue is initially allocated with space for sz
+// elements (pending, and due). It grows, as required, to accommodate
+// more. The queue must be Kill'ed when no longer needed, in order to
+// stop the timer goroutine.
+func NewDelayQueue[T any](sz int) *DelayQueue[T] {
+	return NewDelayQueueWithContext[T](context.Background(), sz)
+}
+
+// NewDelayQueueWithContext is similar to NewDelayQueue, but uses ctx
+// as the parent of the context that will be used for the timer
+// goroutine's cancelation. Canceling ctx has the same effect as
+// calling Kill.
+func NewDelayQueueWithContext[T any](ctx context.Context,
+	sz int) *DelayQueue[T] {
+	dq := &DelayQueue[T]{
+		pend: pq.New(sz, func(a, b *DelayHandle[T]) bool {
+			return a.at.Before(b.at)
+		}),
+		rdy:    NewQueue[T](sz, Unbounded),
+		notEmp: make(chan struct{}),
+		kick:   make(chan struct{}, 1),
+	}
+	dq.t = task.GoWithContext(ctx, dq.run)
+	return dq
+}
+
+// wakeTimer wakes-up the timer goroutine, so that it re-examines the
+// pending elements.
+func (dq *DelayQueue[T]) wakeTimer() {
+	select {
+	case dq.kick <- struct{}{}:
+	default:
+	}
+}
+
+// wakePop wakes-up the goroutines waiting to pop, if any. Must be
+// called with dq.m held.
+func (dq *DelayQueue[T]) wakePop() {
+	if dq.nPopW > 0 && !dq.closed {
+		close(dq.notEmp)
+		dq.notEmp = make(chan struct{})
+	}
+}
+
+// run is the entry point of the timer goroutine.
+func (dq *DelayQueue[T]) run(ctx context.Context) error {
+	tmr := time.NewTimer(0)
+	defer tmr.Stop()
+	for {
+		dq.m.Lock()
+		now := time.Now()
+		n := 0
+		for hd := dq.pend.PeekHandle(); hd != nil; hd =
+			dq.pend.PeekHandle() {
+			h := hd.Value()
+			if h.at.After(now) {
+				tmr.Reset(h.at.Sub(now))
+				break
+			}
+			dq.pend.Pop()
+			dq.rdy.PushBack(h.v)
+			n++
+		}
+		if n > 0 {
+			dq.wakePop()
+		}
+		if dq.pend.Empty() {
+			tmr.Stop()
+		}
+		dq.m.Unlock()
+
+		select {
+		case <-tmr.C:
+		case <-dq.kick:
+		case <-ctx.Done():
+			dq.close()
+			return nil
+		}
+	}
+}
+
+// close closes the queue, discards the pending elements, and wakes-up
+// all goroutines waiting to pop. It is ok to call close multiple
+// times.
+func (dq *DelayQueue[T]) close() {
+	dq.m.Lock()
+	defer dq.m.Unlock()
+	if dq.closed {
+		return
+	}
+	dq.closed = true
+	dq.pend.Clear()
+	close(dq.notEmp)
+}
+
+// Push adds element v to the queue. The element becomes due (and can
+// be popped) at time at. Returns a handle for the element, or
+// ErrClosed if the queue has been killed.
+func (dq *DelayQueue[T]) Push(v T, at time.Time) (*DelayHandle[T], error) {
+	dq.m.Lock()
+	defer dq.m.Unlock()
+	if dq.closed {
+		return nil, ErrClosed
+	}
+	h := &DelayHandle[T]{v: v, at: at}
+	h.hd = dq.pend.Push(h)
+	if dq.pend.PeekHandle() == h.hd {
+		dq.wakeTimer()
+	}
+	return h, nil
+}
+
+// PushAfter is similar to Push, but the element becomes due after
+// duration d elapses.
+func (dq *DelayQueue[T]) PushAfter(v T,
+	d time.Duration) (*DelayHandle[T], error) {
+	return dq.Push(v, time.Now().Add(d))
+}
+
+// Reschedule changes the due time of the element identified by h to
+// at. Returns ok == false if the element is no longer pending (it has
+// already become due, or it has been canceled), ok == true otherwise.
+func (dq *DelayQueue[T]) Reschedule(h *DelayHandle[T],
+	at time.Time) (ok bool) {
+	dq.m.Lock()
+	defer dq.m.Unlock()
+	top := dq.pend.PeekHandle() == h.hd
+	at0 := h.at
+	h.at = at
+	if !dq.pend.Fix(h.hd) {
+		h.at = at0
+		return false
+	}
+	if top || dq.pend.PeekHandle() == h.hd {
+		dq.wakeTimer()
+	}
+	return true
+}
+
+// Cancel removes the element identified by h from the queue. Returns
+// ok == false if the element is no longer pending (it has already
+// become due, or it has been canceled), ok == true otherwise.
+func (dq *DelayQueue[T]) Cancel(h *DelayHandle[T]) (ok bool) {
+	dq.m.Lock()
+	defer dq.m.Unlock()
+	return dq.pend.Remove(h.hd)
+}
+
+// Pop removes the earliest due element from the queue and returns
+// it. If no element is due, Pop waits until one becomes due, the
+// queue is killed, or ctx is done. Returns ErrClosed if the queue has
+// been killed and no due elements remain, or ctx.Err() if ctx was
+// done before an element could be removed.
+func (dq *DelayQueue[T]) Pop(ctx context.Context) (v T, err error) {
+	dq.m.Lock()
+	for {
+		var ok bool
+		if v, ok = dq.rdy.PopFront(); ok {
+			dq.m.Unlock()
+			return v, nil
+		}
+		if dq.closed {
+			dq.m.Unlock()
+			return v, ErrClosed
+		}
+		dq.nPopW++
+		ch := dq.notEmp
+		dq.m.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		dq.m.Lock()
+		dq.nPopW--
+		if err != nil {
+			dq.m.Unlock()
+			return v, err
+		}
+	}
+}
+
+// TryPop removes the earliest due element from the queue and returns
+// it, without waiting. Returns ErrEmpty if no element is due, or
+// ErrClosed if no element is due and the queue has been killed.
+func (dq *DelayQueue[T]) TryPop() (v T, err error) {
+	dq.m.Lock()
+	defer dq.m.Unlock()
+	var ok bool
+	if v, ok = dq.rdy.PopFront(); !ok {
+		if dq.closed {
+			return v, ErrClosed
+		}
+		return v, ErrEmpty
+	}
+	return v, nil
+}
+
+// Len returns the number of elements in the queue (pending, and due).
+func (dq *DelayQueue[T]) Len() int {
+	dq.m.Lock()
+	defer dq.m.Unlock()
+	return dq.pend.Len() + dq.rdy.Len()
+}
+
+// Kill stops the timer goroutine and closes the queue. Kill returns
+// immediately, without waiting for the goroutine to exit. It is ok to
+// call Kill multiple times.
+func (dq *DelayQueue[T]) Kill() task.Task {
+	dq.close()
+	dq.t.Kill()
+	return dq
+}
+
+// Wait waits for the timer goroutine to exit (after the queue has
+// been killed). It always returns nil.
+func (dq *DelayQueue[T]) Wait() error {
+	return dq.t.Wait()
+}
+
+// WaitChan returns a channel that will be closed when the timer
+// goroutine exits. Useful for select statements.
+func (dq *DelayQueue[T]) WaitChan() <-chan struct{} {
+	return dq.t.WaitChan()
+}
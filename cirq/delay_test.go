@@ -0,0 +1,139 @@
+package cirq
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDelayOrder(t *testing.T) {
+	dq := NewDelayQueue[int](2)
+	defer dq.Kill()
+	now := time.Now()
+	for _, i := range []int{3, 1, 4, 0, 2} {
+		_, err := dq.Push(i, now.Add(time.Duration(i)*10*time.Millisecond))
+		if err != nil {
+			t.Fatalf("Cannot push %d: %v", i, err)
+		}
+	}
+	if _, err := dq.TryPop(); err != ErrEmpty && err != nil {
+		t.Fatalf("TryPop: %v", err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		v, err := dq.Pop(ctx)
+		if err != nil {
+			t.Fatalf("Cannot pop %d: %v", i, err)
+		}
+		if v != i {
+			t.Fatalf("Bad element %d != %d", v, i)
+		}
+		if d := now.Add(time.Duration(i) * 10 * time.Millisecond); time.Now().Before(d) {
+			t.Fatalf("Element %d popped early", i)
+		}
+	}
+	if dq.Len() != 0 {
+		t.Fatalf("Len %d != 0", dq.Len())
+	}
+}
+
+func TestDelayReschedCancel(t *testing.T) {
+	dq := NewDelayQueue[string](1)
+	defer dq.Kill()
+	ha, _ := dq.PushAfter("a", time.Hour)
+	hb, _ := dq.PushAfter("b", time.Hour)
+	hc, _ := dq.PushAfter("c", 20*time.Millisecond)
+	if !dq.Cancel(hc) {
+		t.Fatal("Cannot cancel c")
+	}
+	if dq.Cancel(hc) {
+		t.Fatal("Canceled c twice")
+	}
+	if !dq.Reschedule(hb, time.Now()) {
+		t.Fatal("Cannot reschedule b")
+	}
+	ctx := context.Background()
+	if v, err := dq.Pop(ctx); err != nil || v != "b" {
+		t.Fatalf("Pop: %q, %v", v, err)
+	}
+	if dq.Reschedule(hb, time.Now()) {
+		t.Fatal("Rescheduled popped element")
+	}
+	if !dq.Reschedule(ha, time.Now().Add(10*time.Millisecond)) {
+		t.Fatal("Cannot reschedule a")
+	}
+	if v, err := dq.Pop(ctx); err != nil || v != "a" {
+		t.Fatalf("Pop: %q, %v", v, err)
+	}
+	if ha.Value() != "a" {
+		t.Fatalf("Bad handle value %q", ha.Value())
+	}
+}
+
+func TestDelayCtx(t *testing.T) {
+	dq := NewDelayQueue[int](1)
+	defer dq.Kill()
+	dq.PushAfter(0, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+	if _, err := dq.Pop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Pop: %v != %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestDelayKill(t *testing.T) {
+	dq := NewDelayQueue[int](1)
+	dq.Push(0, time.Now())
+	dq.PushAfter(1, time.Hour)
+	if dq.Len() != 2 {
+		t.Fatalf("Len %d != 2", dq.Len())
+	}
+	// Wait for the first element to become due.
+	for {
+		if v, err := dq.TryPop(); err == nil {
+			if v != 0 {
+				t.Fatalf("Bad element %d != 0", v)
+			}
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	dq.PushAfter(2, 0)
+	time.Sleep(10 * time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		ctx := context.Background()
+		dq.Pop(ctx)
+		_, err := dq.Pop(ctx)
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := dq.Kill().Wait(); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if err := <-done; err != ErrClosed {
+		t.Fatalf("Pop after kill: %v != %v", err, ErrClosed)
+	}
+	if _, err := dq.Push(3, time.Now()); err != ErrClosed {
+		t.Fatalf("Push after kill: %v != %v", err, ErrClosed)
+	}
+	if dq.Len() != 0 {
+		t.Fatalf("Len %d != 0", dq.Len())
+	}
+}
+
+func TestDelayParentCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	dq := NewDelayQueueWithContext[int](ctx, 1)
+	cancel()
+	select {
+	case <-dq.WaitChan():
+	case <-time.After(time.Second):
+		t.Fatal("Timer goroutine not stopped")
+	}
+	if _, err := dq.TryPop(); err != ErrClosed {
+		t.Fatalf("TryPop: %v != %v", err, ErrClosed)
+	}
+}
@@ -0,0 +1,553 @@
+// Copyright (c) 2016, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+// Package diskq provides a persistent, file-backed, FIFO queue of
+// records (byte slices). It is a disk-based variant of the cirq
+// circular queue, and uses the same vocabulary (PushBack, PopFront,
+// PeekFront). It is meant for spools, journals, and similar uses that
+// must survive process crashes.
+//
+// The queue is stored in a file of fixed size, allocated when the
+// queue is created. The file starts with a header, followed by the
+// data region, which is used as a ring buffer for the records. The
+// header is stored in two alternating slots, each protected by a
+// checksum, so that a torn header write never destroys the previous
+// valid one. It holds the size of the data region, the offset and
+// the sequence number of the front (head) record, and the recovery
+// epoch (see below).
+//
+// Each record is stored contiguously in the data region, as a 16 byte
+// record header (payload length, CRC-32C checksum, sequence number,
+// and recovery epoch), followed by the payload. When a record does not fit
+// between the tail and the end of the data region, it is stored at
+// its start, and a wrap marker is written at the tail (if there is
+// room for one). If the queue is empty, the head and the tail are
+// instead moved to the start of the data region. The position of the tail is not stored in the
+// header. It is recovered, when the queue is opened, by scanning the
+// records starting from the head, for as long as they have valid
+// checksums and consecutive sequence numbers. A record that was only
+// partially written when the process crashed (a torn write) fails
+// these checks, and is discarded along with everything after it.
+//
+// Records after a discarded one may still be intact, and a new record
+// stored in place of the discarded one could make them appear valid
+// again. To prevent this, the epoch in the header is incremented (and
+// synced) every time the queue is opened, and records are stamped
+// with it. When scanning, the epochs of consecutive records must not
+// decrease, so records left over from before a recovery are not
+// accepted after records written since.
+//
+// Durability depends on the sync policy (see Options). Records are
+// guaranteed to survive a system crash only once they have been
+// synced. Removing records updates the header, and, after a crash,
+// records that had been popped, but whose removal had not been synced,
+// may reappear (i.e. delivery is at-least-once). Space freed by
+// removing records is not reused until the header recording the
+// removal has been synced; if PushBack needs this space, it syncs the
+// file first, regardless of the sync policy.
+//
+// Queue operations are *NOT* thread safe.
+package diskq
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+
+	"github.com/npat-efault/gohacks/cirq"
+)
+
+var (
+	ErrCorrupt  = errors.New("Corrupt queue file")
+	ErrSize     = errors.New("Invalid queue file size")
+	ErrTooLarge = errors.New("Record too large")
+)
+
+// File layout parameters.
+const (
+	magic    = "CIRQDSKQ"
+	version  = 2
+	slotSz   = 64           /* size of a header slot */
+	dataOff  = 2 * slotSz   /* offset of the data region */
+	recHdrSz = 16           /* size of a record header */
+	wrapMark = ^uint32(0)   /* record length of a wrap marker */
+	minSize  = 2 * recHdrSz /* min size of the data region */
+	maxSize  = 1<<63 - 1 - dataOff
+)
+
+var crcTab = crc32.MakeTable(crc32.Castagnoli)
+
+// SyncPolicy determines when the queue file is synced to stable
+// storage (see Options).
+type SyncPolicy int
+
+const (
+	// SyncNone never syncs the file automatically. It is left to
+	// the operating system, or to explicit calls to Queue.Sync.
+	SyncNone SyncPolicy = iota
+	// SyncAlways syncs the file after every PushBack and
+	// PopFront.
+	SyncAlways
+	// SyncEveryN syncs the file after every N operations
+	// (PushBack or PopFront), where N is given by
+	// Options.SyncN.
+	SyncEveryN
+)
+
+// Options are optional queue parameters, given when the queue is
+// opened (see Open).
+type Options struct {
+	// Sync is the sync policy. The default is SyncNone.
+	Sync SyncPolicy
+	// SyncN is the number of operations between syncs, for the
+	// SyncEveryN policy. It must be positive.
+	SyncN int
+}
+
+// Queue is a persistent, file-backed, FIFO queue of records.
+type Queue struct {
+	f       *os.File
+	sz      uint64 /* size of the data region */
+	gen     uint64 /* header generation (selects slot) */
+	head    uint64 /* offset of the front record */
+	headSeq uint64 /* sequence number of the front record */
+	tail    uint64 /* offset of the next record to push */
+	tailSeq uint64 /* sequence number of the next record to push */
+	n       int    /* # of records in the queue */
+	dHead   uint64 /* offset of the front record, as of the last sync */
+	dn      int    /* # of records from dHead to tail */
+	epoch   uint32 /* recovery epoch, stamped on records */
+	sync    SyncPolicy
+	syncN   int
+	nOps    int    /* # of operations since last sync */
+	hb      []byte /* scratch buffer for headers */
+}
+
+// Open opens the persistent queue stored in the file at path, and
+// recovers its contents. If the file does not exist, it is created,
+// with a data region of size bytes, and the queue is empty. The size
+// argument is ignored if the file exists (opening an existing file
+// writes and syncs its header, to start a new recovery
+// epoch). Returns ErrCorrupt if the file exists but does not hold a
+// valid queue, ErrSize if size is invalid, and cirq.ErrOptions if
+// opts are invalid (opts may be nil).
+func Open(path string, size int64, opts *Options) (*Queue, error) {
+	q := &Queue{hb: make([]byte, slotSz)}
+	if opts != nil {
+		if opts.Sync < SyncNone || opts.Sync > SyncEveryN ||
+			opts.Sync == SyncEveryN && opts.SyncN <= 0 {
+			return nil, cirq.ErrOptions
+		}
+		q.sync, q.syncN = opts.Sync, opts.SyncN
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return create(q, path, size)
+	}
+	if err != nil {
+		return nil, err
+	}
+	q.f = f
+	if err := q.recover(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// create creates and initializes the queue file.
+func create(q *Queue, path string, size int64) (*Queue, error) {
+	if size < minSize || size > maxSize {
+		return nil, ErrSize
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return nil, err
+	}
+	q.f, q.sz = f, uint64(size)
+	err = f.Truncate(dataOff + size)
+	if err == nil {
+		err = q.writeHeader(q.head, q.headSeq)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return q, nil
+}
+
+// writeHeader writes a queue header, with front record offset head
+// and sequence number headSeq, to the next header slot. The header
+// generation is advanced only if the write succeeds.
+func (q *Queue) writeHeader(head, headSeq uint64) error {
+	gen := q.gen + 1
+	b := q.hb
+	clear(b)
+	copy(b, magic)
+	binary.LittleEndian.PutUint32(b[8:], version)
+	binary.LittleEndian.PutUint64(b[12:], gen)
+	binary.LittleEndian.PutUint64(b[20:], q.sz)
+	binary.LittleEndian.PutUint64(b[28:], head)
+	binary.LittleEndian.PutUint64(b[36:], headSeq)
+	binary.LittleEndian.PutUint32(b[44:], q.epoch)
+	binary.LittleEndian.PutUint32(b[48:], crc32.Checksum(b[:48], crcTab))
+	if _, err := q.f.WriteAt(b, int64(gen%2)*slotSz); err != nil {
+		return err
+	}
+	q.gen = gen
+	return nil
+}
+
+// readHeader reads and validates the header slot i. Returns ok ==
+// false if the slot is not valid.
+func (q *Queue) readHeader(i int) (gen, sz, head, headSeq uint64,
+	epoch uint32, ok bool, err error) {
+	b := q.hb
+	if _, err = q.f.ReadAt(b, int64(i)*slotSz); err != nil {
+		return
+	}
+	if string(b[:8]) != magic ||
+		binary.LittleEndian.Uint32(b[8:]) != version ||
+		binary.LittleEndian.Uint32(b[48:]) !=
+			crc32.Checksum(b[:48], crcTab) {
+		return
+	}
+	gen = binary.LittleEndian.Uint64(b[12:])
+	sz = binary.LittleEndian.Uint64(b[20:])
+	head = binary.LittleEndian.Uint64(b[28:])
+	headSeq = binary.LittleEndian.Uint64(b[36:])
+	epoch = binary.LittleEndian.Uint32(b[44:])
+	ok = sz >= minSize && sz <= maxSize && head < sz
+	return
+}
+
+// recover reads the queue header from the file, recovers the queue
+// tail by scanning the records, and starts a new recovery epoch.
+func (q *Queue) recover() error {
+	found := false
+	for i := 0; i < 2; i++ {
+		gen, sz, head, headSeq, epoch, ok, err := q.readHeader(i)
+		if err != nil {
+			return ErrCorrupt
+		}
+		if ok && (!found || gen > q.gen) {
+			q.gen, q.sz, q.head, q.headSeq = gen, sz, head, headSeq
+			q.epoch = epoch
+			found = true
+		}
+	}
+	if !found {
+		return ErrCorrupt
+	}
+	if fi, err := q.f.Stat(); err != nil {
+		return err
+	} else if fi.Size() < int64(dataOff+q.sz) {
+		return ErrCorrupt
+	}
+	q.tail, q.tailSeq = q.head, q.headSeq
+	var ep uint32
+	for {
+		pos, l, e, ok, err := q.readRecHdr(q.tail, q.tailSeq, ep)
+		if err != nil {
+			return err
+		}
+		if !ok || !q.fits(pos, l) {
+			break
+		}
+		if ok, err = q.checkRec(pos, l, q.tailSeq); err != nil {
+			return err
+		} else if !ok {
+			break
+		}
+		q.tail = pos + recHdrSz + l
+		q.tailSeq++
+		q.n++
+		ep = e
+	}
+	q.dHead, q.dn = q.head, q.n
+	// Records written from now on must be distinguishable from
+	// any left over beyond the recovered tail.
+	q.epoch++
+	if err := q.writeHeader(q.head, q.headSeq); err != nil {
+		return err
+	}
+	return q.f.Sync()
+}
+
+// fits tests if a record with payload length l, stored at offset pos,
+// fits in the free space of the data region (used by recover).
+func (q *Queue) fits(pos, l uint64) bool {
+	if l > q.sz-recHdrSz || pos+recHdrSz+l > q.sz {
+		return false
+	}
+	end := pos + recHdrSz + l
+	if q.n == 0 {
+		return pos >= q.head || end <= q.head
+	}
+	if q.tail > q.head {
+		return pos >= q.tail || end <= q.head
+	}
+	return pos >= q.tail && end <= q.head
+}
+
+// wrapPos returns the offset at which a record header, following a
+// record that ends at offset pos, is stored, if it is not stored at
+// the start of the data region.
+func (q *Queue) wrapPos(pos uint64) uint64 {
+	if q.sz-pos < recHdrSz {
+		return 0
+	}
+	return pos
+}
+
+// putRecHdr fills the record header b, except for the checksum, for
+// a record with payload length l and sequence number seq. Only the
+// low 32 bits of the sequence number are stored.
+func (q *Queue) putRecHdr(b []byte, l uint32, seq uint64) {
+	binary.LittleEndian.PutUint32(b[0:], l)
+	binary.LittleEndian.PutUint32(b[8:], uint32(seq))
+	binary.LittleEndian.PutUint32(b[12:], q.epoch)
+}
+
+// readRecHdr reads the header of the record with sequence number seq,
+// that follows a record ending at offset pos, following a wrap marker
+// if there is one. The record (and the wrap marker) must have an
+// epoch no less than minEp, and no greater than the current one. It
+// returns the offset of the record header, the payload length, and
+// the record's epoch. Returns ok == false if there is no valid record
+// header.
+func (q *Queue) readRecHdr(pos, seq uint64, minEp uint32) (rpos, l uint64,
+	ep uint32, ok bool, err error) {
+	b := q.hb[:recHdrSz]
+	for i := 0; i < 2; i++ {
+		pos = q.wrapPos(pos)
+		if _, err = q.f.ReadAt(b, int64(dataOff+pos)); err != nil {
+			return 0, 0, 0, false, err
+		}
+		ln := binary.LittleEndian.Uint32(b[0:])
+		ep = binary.LittleEndian.Uint32(b[12:])
+		if binary.LittleEndian.Uint32(b[8:]) != uint32(seq) ||
+			ep < minEp || ep > q.epoch {
+			return 0, 0, 0, false, nil
+		}
+		if ln != wrapMark {
+			return pos, uint64(ln), ep, true, nil
+		}
+		if binary.LittleEndian.Uint32(b[4:]) !=
+			crc32.Checksum(b[8:], crcTab) {
+			return 0, 0, 0, false, nil
+		}
+		pos, minEp = q.sz, ep
+	}
+	return 0, 0, 0, false, nil
+}
+
+// checkRec reads the record stored at offset pos, with payload length
+// l, and verifies its checksum.
+func (q *Queue) checkRec(pos, l, seq uint64) (ok bool, err error) {
+	b := make([]byte, recHdrSz+l)
+	if _, err = q.f.ReadAt(b, int64(dataOff+pos)); err != nil {
+		return false, err
+	}
+	return binary.LittleEndian.Uint32(b[4:]) ==
+		crc32.Checksum(b[8:], crcTab), nil
+}
+
+// Len returns the number of records in the queue.
+func (q *Queue) Len() int {
+	return q.n
+}
+
+// Empty tests if the queue is empty.
+func (q *Queue) Empty() bool {
+	return q.n == 0
+}
+
+// Size returns the size of the queue's data region in bytes. Each
+// record occupies 16 bytes in addition to its payload.
+func (q *Queue) Size() int64 {
+	return int64(q.sz)
+}
+
+// space returns the offset at which a record with payload length l
+// can be stored, and if a wrap marker is required, given that the
+// front record is at offset head and there are n records in the
+// queue. Returns ok == false if there is not enough free space. If
+// the queue is empty, and the record does not fit at the tail, it is
+// stored at the start of the data region without a wrap marker (see
+// PushBack).
+func (q *Queue) space(l, head uint64, n int) (pos uint64, wrap, ok bool) {
+	need := recHdrSz + l
+	if n == 0 {
+		if q.sz-q.tail >= need {
+			return q.tail, false, true
+		}
+		return 0, false, need <= q.sz
+	}
+	if q.tail > head {
+		if q.sz-q.tail >= need {
+			return q.tail, false, true
+		}
+		return 0, q.sz-q.tail >= recHdrSz, need <= head
+	}
+	return q.tail, false, head-q.tail >= need
+}
+
+// PushBack adds record rec to the back (tail) of the queue. Returns
+// ErrTooLarge if the record can never fit in the queue, and
+// cirq.ErrFull if there is not enough free space for it at the
+// moment.
+func (q *Queue) PushBack(rec []byte) error {
+	if q.f == nil {
+		return cirq.ErrClosed
+	}
+	l := uint64(len(rec))
+	if l > q.sz-recHdrSz || l >= uint64(wrapMark) {
+		return ErrTooLarge
+	}
+	// Space freed by pops is not reused until the header
+	// recording them has been synced. Otherwise, after a crash,
+	// the old header could point to records overwritten by new
+	// ones, and the records after them would be lost.
+	pos, wrap, ok := q.space(l, q.dHead, q.dn)
+	if !ok && q.dn != q.n {
+		if err := q.Sync(); err != nil {
+			return err
+		}
+		pos, wrap, ok = q.space(l, q.dHead, q.dn)
+	}
+	if !ok {
+		return cirq.ErrFull
+	}
+	if q.dn == 0 && pos != q.tail {
+		// The queue is empty, and the record does not fit at
+		// the tail. Move the head and the tail to the start of
+		// the data region, so that the record can use all of
+		// it. There are no synced records to protect, so the
+		// header need not be synced first.
+		if err := q.writeHeader(0, q.headSeq); err != nil {
+			return err
+		}
+		q.head, q.tail, q.dHead = 0, 0, 0
+	}
+	if wrap {
+		b := q.hb[:recHdrSz]
+		q.putRecHdr(b, wrapMark, q.tailSeq)
+		binary.LittleEndian.PutUint32(b[4:],
+			crc32.Checksum(b[8:], crcTab))
+		if _, err := q.f.WriteAt(b, int64(dataOff+q.tail)); err != nil {
+			return err
+		}
+	}
+	b := make([]byte, recHdrSz+l)
+	q.putRecHdr(b, uint32(l), q.tailSeq)
+	copy(b[recHdrSz:], rec)
+	binary.LittleEndian.PutUint32(b[4:], crc32.Checksum(b[8:], crcTab))
+	if _, err := q.f.WriteAt(b, int64(dataOff+pos)); err != nil {
+		return err
+	}
+	q.tail = pos + recHdrSz + l
+	q.tailSeq++
+	q.n++
+	q.dn++
+	return q.synced()
+}
+
+// front reads the front record of the queue. Returns its offset and
+// its payload.
+func (q *Queue) front() (pos uint64, rec []byte, err error) {
+	if q.f == nil {
+		return 0, nil, cirq.ErrClosed
+	}
+	if q.n == 0 {
+		return 0, nil, cirq.ErrEmpty
+	}
+	pos, l, _, ok, err := q.readRecHdr(q.head, q.headSeq, 0)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !ok {
+		return 0, nil, ErrCorrupt
+	}
+	b := make([]byte, recHdrSz+l)
+	if _, err = q.f.ReadAt(b, int64(dataOff+pos)); err != nil {
+		return 0, nil, err
+	}
+	if binary.LittleEndian.Uint32(b[4:]) != crc32.Checksum(b[8:], crcTab) {
+		return 0, nil, ErrCorrupt
+	}
+	return pos, b[recHdrSz:], nil
+}
+
+// PeekFront returns the front (head) record of the queue, without
+// removing it. Returns cirq.ErrEmpty if the queue is empty, and
+// ErrCorrupt if the record cannot be read back intact.
+func (q *Queue) PeekFront() (rec []byte, err error) {
+	_, rec, err = q.front()
+	return rec, err
+}
+
+// PopFront removes the front (head) record from the queue and returns
+// it. Returns cirq.ErrEmpty if the queue is empty, and ErrCorrupt if
+// the record cannot be read back intact. In these cases, and if the
+// updated header cannot be written, the record is not removed.
+func (q *Queue) PopFront() (rec []byte, err error) {
+	pos, rec, err := q.front()
+	if err != nil {
+		return nil, err
+	}
+	head := q.wrapPos(pos + recHdrSz + uint64(len(rec)))
+	if err := q.writeHeader(head, q.headSeq+1); err != nil {
+		return nil, err
+	}
+	q.head = head
+	q.headSeq++
+	q.n--
+	return rec, q.synced()
+}
+
+// synced syncs the queue file, if required by the sync policy, after
+// an operation.
+func (q *Queue) synced() error {
+	q.nOps++
+	switch {
+	case q.sync == SyncAlways,
+		q.sync == SyncEveryN && q.nOps >= q.syncN:
+		return q.Sync()
+	}
+	return nil
+}
+
+// Sync commits the queue file to stable storage.
+func (q *Queue) Sync() error {
+	if q.f == nil {
+		return cirq.ErrClosed
+	}
+	q.nOps = 0
+	if err := q.f.Sync(); err != nil {
+		return err
+	}
+	q.dHead, q.dn = q.head, q.n
+	return nil
+}
+
+// Close syncs and closes the queue file. After Close, all queue
+// operations fail with cirq.ErrClosed.
+func (q *Queue) Close() error {
+	if q.f == nil {
+		return cirq.ErrClosed
+	}
+	err := q.f.Sync()
+	if cerr := q.f.Close(); err == nil {
+		err = cerr
+	}
+	q.f = nil
+	return err
+}
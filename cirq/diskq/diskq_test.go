@@ -0,0 +1,406 @@
+package diskq
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/npat-efault/gohacks/cirq"
+)
+
+func rec(i int) []byte {
+	return []byte(fmt.Sprintf("record-%d-%s", i,
+		bytes.Repeat([]byte{'x'}, i%23)))
+}
+
+func mustOpen(t *testing.T, path string, size int64, opts *Options) *Queue {
+	t.Helper()
+	q, err := Open(path, size, opts)
+	if err != nil {
+		t.Fatalf("Cannot open %s: %v", path, err)
+	}
+	return q
+}
+
+func popExpect(t *testing.T, q *Queue, want []byte) {
+	t.Helper()
+	r, err := q.PeekFront()
+	if err != nil {
+		t.Fatalf("PeekFront: %v", err)
+	}
+	if !bytes.Equal(r, want) {
+		t.Fatalf("PeekFront: %q != %q", r, want)
+	}
+	r, err = q.PopFront()
+	if err != nil {
+		t.Fatalf("PopFront: %v", err)
+	}
+	if !bytes.Equal(r, want) {
+		t.Fatalf("PopFront: %q != %q", r, want)
+	}
+}
+
+func TestBasic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "q")
+	q := mustOpen(t, path, 1024, &Options{Sync: SyncAlways})
+	if _, err := q.PopFront(); err != cirq.ErrEmpty {
+		t.Fatalf("Pop from empty Q: %v != %v", err, cirq.ErrEmpty)
+	}
+	for i := 0; i < 10; i++ {
+		if err := q.PushBack(rec(i)); err != nil {
+			t.Fatalf("PushBack %d: %v", i, err)
+		}
+	}
+	popExpect(t, q, rec(0))
+	popExpect(t, q, rec(1))
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := q.PushBack(rec(0)); err != cirq.ErrClosed {
+		t.Fatalf("PushBack to closed Q: %v != %v", err, cirq.ErrClosed)
+	}
+
+	q = mustOpen(t, path, 0, nil)
+	defer q.Close()
+	if q.Len() != 8 || q.Size() != 1024 {
+		t.Fatalf("Reopened Q len/size %d/%d != 8/1024",
+			q.Len(), q.Size())
+	}
+	for i := 2; i < 10; i++ {
+		popExpect(t, q, rec(i))
+	}
+	if !q.Empty() {
+		t.Fatal("Q not empty")
+	}
+}
+
+func TestFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "q")
+	q := mustOpen(t, path, 256, nil)
+	defer q.Close()
+	if err := q.PushBack(make([]byte, 256-recHdrSz+1)); err != ErrTooLarge {
+		t.Fatalf("PushBack: %v != %v", err, ErrTooLarge)
+	}
+	r := make([]byte, 48) // 64 bytes with header
+	for i := 0; i < 4; i++ {
+		if err := q.PushBack(r); err != nil {
+			t.Fatalf("PushBack %d: %v", i, err)
+		}
+	}
+	if err := q.PushBack(nil); err != cirq.ErrFull {
+		t.Fatalf("PushBack to full Q: %v != %v", err, cirq.ErrFull)
+	}
+	q.PopFront()
+	if err := q.PushBack(make([]byte, 49)); err != cirq.ErrFull {
+		t.Fatalf("PushBack to full Q: %v != %v", err, cirq.ErrFull)
+	}
+	if err := q.PushBack(r); err != nil {
+		t.Fatalf("PushBack after pop: %v", err)
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "q"), minSize-1,
+		nil); err != ErrSize {
+		t.Fatalf("Open: %v != %v", err, ErrSize)
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "q"), 1024,
+		&Options{Sync: SyncEveryN}); err != cirq.ErrOptions {
+		t.Fatalf("Open: %v != %v", err, cirq.ErrOptions)
+	}
+}
+
+// TestRandom pushes and pops random records, wrapping around the data
+// region many times, and re-opening the queue periodically. It
+// compares the results against a cirq.Queue.
+func TestRandom(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "q")
+	q := mustOpen(t, path, 1000, &Options{Sync: SyncEveryN, SyncN: 16})
+	m := cirq.NewQueue[[]byte](16, cirq.Unbounded)
+	for i := 0; i < 20000; i++ {
+		switch rand.Intn(4) {
+		case 0, 1:
+			r := make([]byte, rand.Intn(100))
+			rand.Read(r)
+			err := q.PushBack(r)
+			if err == cirq.ErrFull {
+				continue
+			}
+			if err != nil {
+				t.Fatalf("PushBack: %v", err)
+			}
+			m.PushBack(r)
+		case 2:
+			r, ok := m.PopFront()
+			if !ok {
+				if _, err := q.PopFront(); err != cirq.ErrEmpty {
+					t.Fatalf("Pop from empty Q: %v", err)
+				}
+				continue
+			}
+			popExpect(t, q, r)
+		case 3:
+			if rand.Intn(50) == 0 {
+				q.Close()
+				q = mustOpen(t, path, 0, nil)
+			}
+		}
+		if q.Len() != m.Len() {
+			t.Fatalf("Q len %d != %d", q.Len(), m.Len())
+		}
+	}
+	q.Close()
+}
+
+// corrupt flips a byte at offset off of the file at path.
+func corrupt(t *testing.T, path string, off int64) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b := make([]byte, 1)
+	f.ReadAt(b, off)
+	b[0] ^= 0xff
+	f.WriteAt(b, off)
+}
+
+func TestTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "q")
+	q := mustOpen(t, path, 1024, nil)
+	for i := 0; i < 3; i++ {
+		q.PushBack(rec(i))
+	}
+	off := q.tail - 1 // last byte of the last record
+	q.Close()
+	corrupt(t, path, dataOff+int64(off))
+
+	q = mustOpen(t, path, 0, nil)
+	if q.Len() != 2 {
+		t.Fatalf("Recovered Q len %d != 2", q.Len())
+	}
+	q.PushBack(rec(10))
+	q.Close()
+	q = mustOpen(t, path, 0, nil)
+	defer q.Close()
+	for _, i := range []int{0, 1, 10} {
+		popExpect(t, q, rec(i))
+	}
+}
+
+func TestTornHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "q")
+	q := mustOpen(t, path, 1024, nil)
+	for i := 0; i < 3; i++ {
+		q.PushBack(rec(i))
+	}
+	q.PopFront()
+	slot := int64(q.gen % 2)
+	q.Close()
+	// Destroy the latest header; the previous one is used, and
+	// the popped record reappears.
+	corrupt(t, path, slot*slotSz+30)
+	q = mustOpen(t, path, 0, nil)
+	for i := 0; i < 3; i++ {
+		popExpect(t, q, rec(i))
+	}
+	q.Close()
+	// Destroy both headers.
+	corrupt(t, path, 30)
+	corrupt(t, path, slotSz+30)
+	if _, err := Open(path, 0, nil); err != ErrCorrupt {
+		t.Fatalf("Open: %v != %v", err, ErrCorrupt)
+	}
+}
+
+// hdrSnap returns the header slots of the queue file at path, if the
+// header was last synced (that is, if it would survive a system
+// crash), or the previous snapshot prev otherwise.
+func hdrSnap(t *testing.T, q *Queue, path string, prev []byte) []byte {
+	t.Helper()
+	if q.dHead != q.head || q.dn != q.n {
+		return prev
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b[:dataOff]
+}
+
+// crash simulates a system crash, after which the header writes that
+// were not synced are lost: It closes the queue, restores the header
+// slots from snapshot hdr, and re-opens the queue.
+func crash(t *testing.T, q *Queue, path string, hdr []byte) *Queue {
+	t.Helper()
+	q.Close()
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(hdr, 0); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	return mustOpen(t, path, 0, nil)
+}
+
+func TestUnsyncedPop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "q")
+	q := mustOpen(t, path, 96, &Options{Sync: SyncEveryN, SyncN: 100})
+	r := func(c byte) []byte { return bytes.Repeat([]byte{c}, 16) }
+	for _, c := range "ABC" {
+		if err := q.PushBack(r(byte(c))); err != nil {
+			t.Fatalf("PushBack: %v", err)
+		}
+	}
+	q.Sync()
+	hdr := hdrSnap(t, q, path, nil)
+	popExpect(t, q, r('A'))
+	hdr = hdrSnap(t, q, path, hdr)
+	// Needs the space freed by the pop.
+	if err := q.PushBack(r('X')); err != nil {
+		t.Fatalf("PushBack: %v", err)
+	}
+	hdr = hdrSnap(t, q, path, hdr)
+	q = crash(t, q, path, hdr)
+	for _, c := range "BCX" {
+		popExpect(t, q, r(byte(c)))
+	}
+	// Pop so that the front record ends at the end of the data
+	// region.
+	hdr = hdrSnap(t, q, path, hdr)
+	q.PushBack(r('Y'))
+	q.PushBack(r('Z'))
+	popExpect(t, q, r('Y'))
+	q.Sync()
+	hdr = hdrSnap(t, q, path, hdr)
+	q = crash(t, q, path, hdr)
+	defer q.Close()
+	if q.Len() != 1 {
+		t.Fatalf("Recovered Q len %d != 1", q.Len())
+	}
+	popExpect(t, q, r('Z'))
+}
+
+// TestRandomCrash pushes and pops random records, and simulates
+// system crashes at random points. It checks that no record that was
+// synced is lost.
+func TestRandomCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "q")
+	q := mustOpen(t, path, 500, &Options{Sync: SyncEveryN, SyncN: 7})
+	m := cirq.NewQueue[[]byte](16, cirq.Unbounded)
+	// Records popped since the last header snapshot; they
+	// reappear after a crash.
+	var popped [][]byte
+	hdr := hdrSnap(t, q, path, nil)
+	for i := 0; i < 20000; i++ {
+		switch rand.Intn(5) {
+		case 0, 1:
+			r := make([]byte, rand.Intn(60))
+			rand.Read(r)
+			// PushBack may sync, even if it fails.
+			switch err := q.PushBack(r); err {
+			case nil:
+				m.PushBack(r)
+			case cirq.ErrFull:
+			default:
+				t.Fatalf("PushBack: %v", err)
+			}
+		case 2, 3:
+			if r, ok := m.PopFront(); ok {
+				popExpect(t, q, r)
+				popped = append(popped, r)
+			}
+		case 4:
+			if rand.Intn(20) == 0 {
+				q = crash(t, q, path, hdr)
+				for len(popped) > 0 {
+					m.PushFront(popped[len(popped)-1])
+					popped = popped[:len(popped)-1]
+				}
+			}
+		}
+		if h := hdrSnap(t, q, path, nil); h != nil {
+			hdr, popped = h, popped[:0]
+		}
+		if q.Len() != m.Len() {
+			t.Fatalf("Q len %d != %d", q.Len(), m.Len())
+		}
+	}
+	q.Close()
+}
+
+func TestPopWriteError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "q")
+	q := mustOpen(t, path, 1024, nil)
+	defer q.Close()
+	q.PushBack(rec(0))
+	q.PushBack(rec(1))
+	// Make header writes fail.
+	f := q.f
+	ro, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.f = ro
+	gen := q.gen
+	if _, err := q.PopFront(); err == nil {
+		t.Fatal("PopFront with read-only file succeeded")
+	}
+	q.f = f
+	ro.Close()
+	if q.Len() != 2 || q.gen != gen {
+		t.Fatalf("Q len/gen %d/%d != 2/%d", q.Len(), q.gen, gen)
+	}
+	popExpect(t, q, rec(0))
+	popExpect(t, q, rec(1))
+}
+
+func TestEmptyWrap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "q")
+	q := mustOpen(t, path, 1024, nil)
+	r0, r1 := bytes.Repeat([]byte{'a'}, 884), bytes.Repeat([]byte{'b'}, 950)
+	if err := q.PushBack(r0); err != nil {
+		t.Fatalf("PushBack: %v", err)
+	}
+	popExpect(t, q, r0)
+	// Does not fit at the tail, and overlaps it if wrapped.
+	if err := q.PushBack(r1); err != nil {
+		t.Fatalf("PushBack: %v", err)
+	}
+	if _, err := q.PeekFront(); err != nil {
+		t.Fatalf("PeekFront: %v", err)
+	}
+	q.Close()
+	q = mustOpen(t, path, 0, nil)
+	defer q.Close()
+	if q.Len() != 1 {
+		t.Fatalf("Reopened Q len %d != 1", q.Len())
+	}
+	popExpect(t, q, r1)
+}
+
+func TestTornMiddle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "q")
+	q := mustOpen(t, path, 1024, nil)
+	r := func(c byte) []byte { return bytes.Repeat([]byte{c}, 16) }
+	q.PushBack(r('A'))
+	q.PushBack(r('B'))
+	q.Close()
+	corrupt(t, path, dataOff+recHdrSz) // A's payload
+	q = mustOpen(t, path, 0, nil)
+	if q.Len() != 0 {
+		t.Fatalf("Recovered Q len %d != 0", q.Len())
+	}
+	// Takes A's place; B must not come back.
+	q.PushBack(r('C'))
+	q.Close()
+	q = mustOpen(t, path, 0, nil)
+	defer q.Close()
+	if q.Len() != 1 {
+		t.Fatalf("Reopened Q len %d != 1", q.Len())
+	}
+	popExpect(t, q, r('C'))
+}
@@ -6,13 +6,29 @@
 // Package cirq provides a circular double-ended queue
 // implementation. The implementation is based on slices and supports
 // the typical PushFront / PushBack, PopFront / PopBack, PeekFront /
-// PeekBack operations. The queue can grow dynamically and stores
-// elements of type interface{}.
+// PeekBack operations. The queue can grow dynamically. It is
+// parametrized by the type of its elements (see Queue). Type CQ is
+// provided, for compatibility, as a queue that stores elements of
+// type interface{}.
 //
-// You can generate queue implementations specialized to specific
-// element data-types using the cirq_gen.sh script. See "doc.go" in
-// the package sources for an example.
+// Queue operations are not thread safe. BlockingQueue wraps a Queue
+// for use by concurrent producers and consumers. DelayQueue is a
+// thread-safe queue whose elements become available for popping at
+// specific (due) times.
+//
+// ByteRing is a circular byte buffer, using the same design as Queue,
+// that implements the standard io interfaces.
+//
+// The cirqgen command (see cmd/cirqgen), that generates queue
+// implementations specialized to specific element data-types, from
+// the cirq.gox template (see Template), is rarely needed: Queue[T] is
+// much faster than CQ, though its pushes are somewhat slower than
+// those of a generated queue. It is kept for the benefit of existing
+// users, and for the few cases where this difference matters. See
+// "bench_test.go" in the package sources for a comparison between
+// the generic and the generated implementations.
 //
 package cirq
 
-//go:generate ./cirq_gen.sh cirq.go cirq CQ New "interface{}"
+// Generate the specialized int queue used by the benchmarks.
+//go:generate go run github.com/npat-efault/gohacks/cmd/cirqgen -o intq_gen_test.go -pkg cirq_test -type intCQ -new newIntCQ -elem int
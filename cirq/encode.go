@@ -0,0 +1,180 @@
+// Copyright (c) 2014, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package cirq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrTooLarge = errors.New("Q data exceed max size")
+	ErrEncoding = errors.New("Invalid Q encoding")
+)
+
+// queueData is the serialized form of a Queue (see MarshalJSON and
+// GobEncode). MaxCap is Unbounded for unbounded queues.
+type queueData[T any] struct {
+	Cap    int `json:"cap"`
+	MaxCap int `json:"max_cap"`
+	Elems  []T `json:"elems"`
+}
+
+// data returns the serialized form of the queue.
+func (cq *Queue[T]) data() *queueData[T] {
+	if cq.shr {
+		cq.shrink()
+	}
+	d := &queueData[T]{
+		Cap: int(cq.sz), MaxCap: int(cq.maxSz),
+		Elems: cq.Slice(),
+	}
+	if cq.maxSz == maxCap {
+		d.MaxCap = Unbounded
+	}
+	return d
+}
+
+// restore replaces the contents, the capacity, and the maximum
+// capacity of the queue with those from d. Options are not changed
+// (except for the minimum capacity, which is clamped to the new
+// maximum).
+func (cq *Queue[T]) restore(d *queueData[T]) error {
+	usz, umaxSz, err := sizes(d.Cap, d.MaxCap)
+	if err != nil {
+		return err
+	}
+	n := uint(len(d.Elems))
+	if n > umaxSz {
+		return ErrTooLarge
+	}
+	if n > usz {
+		usz = roundUp2(n)
+	}
+	cq.sz, cq.maxSz, cq.m = usz, umaxSz, usz-1
+	cq.b = make([]T, usz)
+	copy(cq.b, d.Elems)
+	cq.s, cq.e = 0, n
+	if cq.minSz > umaxSz {
+		cq.minSz = umaxSz
+	}
+	cq.mod++
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface. The queue is
+// encoded as a JSON object with fields "cap" (the current capacity),
+// "max_cap" (the maximum capacity, or -1 if unbounded), and "elems"
+// (an array with the elements of the queue, from front to back).
+func (cq *Queue[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cq.data())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. It
+// replaces the contents, the capacity, and the maximum capacity of
+// the queue with those decoded from data (see MarshalJSON). Queue
+// options are not changed. Returns ErrSize if the decoded capacities
+// are invalid, and ErrTooLarge if the decoded elements exceed the
+// maximum capacity. On error, the queue is not modified.
+func (cq *Queue[T]) UnmarshalJSON(data []byte) error {
+	var d queueData[T]
+	if err := json.Unmarshal(data, &d); err != nil {
+		return err
+	}
+	return cq.restore(&d)
+}
+
+// GobEncode implements the gob.GobEncoder interface. The queue is
+// encoded with the same information as with MarshalJSON.
+func (cq *Queue[T]) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(cq.data()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements the gob.GobDecoder interface. See
+// UnmarshalJSON.
+func (cq *Queue[T]) GobDecode(data []byte) error {
+	var d queueData[T]
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&d); err != nil {
+		return err
+	}
+	return cq.restore(&d)
+}
+
+// brVersion is the version of the ByteRing binary encoding.
+const brVersion = 1
+
+// MarshalBinary implements the encoding.BinaryMarshaler
+// interface. The ring is encoded as a version byte, followed by the
+// current capacity, the maximum capacity (0 if unbounded), and the
+// number of bytes in the ring, all as unsigned varints, followed by
+// the bytes in the ring.
+func (br *ByteRing) MarshalBinary() ([]byte, error) {
+	n := br.e - br.s
+	maxSz := br.maxSz
+	if maxSz == maxCap {
+		maxSz = 0
+	}
+	data := make([]byte, 0, 1+3*binary.MaxVarintLen64+n)
+	data = append(data, brVersion)
+	data = binary.AppendUvarint(data, uint64(br.sz))
+	data = binary.AppendUvarint(data, uint64(maxSz))
+	data = binary.AppendUvarint(data, uint64(n))
+	a, b := br.Bytes()
+	data = append(data, a...)
+	return append(data, b...), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler
+// interface. It replaces the contents, the capacity, and the maximum
+// capacity of the ring with those decoded from data (see
+// MarshalBinary). Returns ErrEncoding if data are malformed, ErrSize
+// if the decoded capacities are invalid, and ErrTooLarge if the
+// decoded bytes exceed the maximum capacity. On error, the ring is
+// not modified.
+func (br *ByteRing) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != brVersion {
+		return ErrEncoding
+	}
+	data = data[1:]
+	var v [3]uint64
+	for i := range v {
+		x, k := binary.Uvarint(data)
+		if k <= 0 || x > maxCap {
+			return ErrEncoding
+		}
+		v[i], data = x, data[k:]
+	}
+	sz, maxSz, n := int(v[0]), int(v[1]), v[2]
+	if maxSz == 0 {
+		maxSz = Unbounded
+	}
+	if n != uint64(len(data)) {
+		return ErrEncoding
+	}
+	usz, umaxSz, err := sizes(sz, maxSz)
+	if err != nil {
+		return err
+	}
+	if uint(n) > umaxSz {
+		return ErrTooLarge
+	}
+	if uint(n) > usz {
+		usz = roundUp2(uint(n))
+	}
+	br.sz, br.maxSz, br.m = usz, umaxSz, usz-1
+	br.b = make([]byte, usz)
+	copy(br.b, data)
+	br.s, br.e = 0, uint(n)
+	br.canUnrd = false
+	return nil
+}
@@ -0,0 +1,135 @@
+package cirq
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	q := mkWrapped(100)
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var r Queue[int]
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if r.Cap() != q.Cap() || r.MaxCap() != q.MaxCap() {
+		t.Fatalf("Restored cap/maxCap %d/%d != %d/%d",
+			r.Cap(), r.MaxCap(), q.Cap(), q.MaxCap())
+	}
+	checkSeq(t, &r, seq(0, 100))
+	r.PushBack(100)
+	checkSeq(t, &r, seq(0, 101))
+
+	data = []byte(`{"cap":2,"max_cap":-1,"elems":["a","b","c"]}`)
+	rs := NewQueue[string](1, 1)
+	if err := json.Unmarshal(data, rs); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if rs.Cap() != 4 || rs.MaxCap() != maxCap {
+		t.Fatalf("Restored cap/maxCap %d/%d", rs.Cap(), rs.MaxCap())
+	}
+	if s := rs.Slice(); !slices.Equal(s, []string{"a", "b", "c"}) {
+		t.Fatalf("Restored %v", s)
+	}
+	data, _ = json.Marshal(rs)
+	if string(data) != `{"cap":4,"max_cap":-1,"elems":["a","b","c"]}` {
+		t.Fatalf("Marshal: %s", data)
+	}
+
+	for _, s := range []string{
+		`{"cap":2,"max_cap":2,"elems":["a","b","c"]}`,
+		`{"cap":8,"max_cap":4,"elems":[]}`,
+		`{"cap":1,"max_cap":4,"elems":[1]}`,
+	} {
+		if err := json.Unmarshal([]byte(s), rs); err == nil {
+			t.Fatalf("Unmarshal %s: no error", s)
+		}
+	}
+	if s := rs.Slice(); !slices.Equal(s, []string{"a", "b", "c"}) {
+		t.Fatalf("Q modified on error: %v", s)
+	}
+	if err := json.Unmarshal([]byte(`{"cap":2,"max_cap":2,"elems":["a","b","c"]}`),
+		rs); err != ErrTooLarge {
+		t.Fatalf("Unmarshal: %v != %v", err, ErrTooLarge)
+	}
+}
+
+func TestGob(t *testing.T) {
+	type work struct {
+		Name string
+		Q    *Queue[int]
+	}
+	w := work{"w", mkWrapped(100)}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&w); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	var r work
+	if err := gob.NewDecoder(&buf).Decode(&r); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if r.Name != "w" || r.Q.Cap() != w.Q.Cap() ||
+		r.Q.MaxCap() != w.Q.MaxCap() {
+		t.Fatalf("Decoded %s %d/%d", r.Name, r.Q.Cap(), r.Q.MaxCap())
+	}
+	checkSeq(t, r.Q, seq(0, 100))
+}
+
+func TestByteRingBinary(t *testing.T) {
+	br := NewByteRing(8, 64)
+	br.Write([]byte("0123456789"))
+	br.Read(make([]byte, 6))
+	br.Write([]byte("abcdefghijkl"))
+	data, err := br.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	r := NewByteRing(1, 1)
+	if err := r.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	if r.Cap() != br.Cap() || r.MaxCap() != 64 {
+		t.Fatalf("Restored cap/maxCap %d/%d", r.Cap(), r.MaxCap())
+	}
+	a, b := r.Bytes()
+	if s := string(a) + string(b); s != "6789abcdefghijkl" {
+		t.Fatalf("Restored %q", s)
+	}
+
+	u := NewByteRing(1, Unbounded)
+	u.Write([]byte("xyz"))
+	data, _ = u.MarshalBinary()
+	if err := r.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	if r.Cap() != 4 || r.MaxCap() != maxCap || r.Len() != 3 {
+		t.Fatalf("Restored cap/maxCap/len %d/%d/%d",
+			r.Cap(), r.MaxCap(), r.Len())
+	}
+
+	for i := 0; i < len(data); i++ {
+		if err := r.UnmarshalBinary(data[:i]); err != ErrEncoding {
+			t.Fatalf("Truncated %d: %v != %v", i, err, ErrEncoding)
+		}
+	}
+	if err := r.UnmarshalBinary(append(data, 'w')); err != ErrEncoding {
+		t.Fatalf("Extra data: %v != %v", err, ErrEncoding)
+	}
+	data = []byte{brVersion, 1, 2, 3, 'a', 'b', 'c'}
+	if err := r.UnmarshalBinary(data); err != ErrTooLarge {
+		t.Fatalf("UnmarshalBinary: %v != %v", err, ErrTooLarge)
+	}
+	data = []byte{brVersion, 4, 2, 0}
+	if err := r.UnmarshalBinary(data); err != ErrSize {
+		t.Fatalf("UnmarshalBinary: %v != %v", err, ErrSize)
+	}
+	if r.Len() != 3 {
+		t.Fatalf("Ring modified on error")
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2014, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package cirq
+
+import "iter"
+
+// All returns an iterator over the indexes and elements of the queue,
+// from front (head) to back (tail). The front element has index
+// 0. The queue must not be modified (elements pushed, popped,
+// inserted or removed) while iterating; if it is, the iterator
+// panics. Replacing elements (with Set) is allowed.
+func (cq *Queue[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		mod := cq.mod
+		for i, n := uint(0), cq.e-cq.s; i < n; i++ {
+			if !yield(int(i), cq.b[(cq.s+i)&cq.m]) {
+				return
+			}
+			if cq.mod != mod {
+				panic("Q modified during iteration")
+			}
+		}
+	}
+}
+
+// Backward returns an iterator over the indexes and elements of the
+// queue, from back (tail) to front (head). Indexes are the same as
+// for All (the back element has index Len()-1). See All for
+// restrictions.
+func (cq *Queue[T]) Backward() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		mod := cq.mod
+		for i := cq.e - cq.s; i > 0; i-- {
+			if !yield(int(i-1), cq.b[(cq.s+i-1)&cq.m]) {
+				return
+			}
+			if cq.mod != mod {
+				panic("Q modified during iteration")
+			}
+		}
+	}
+}
+
+// Drain returns an iterator that removes elements from the front
+// (head) of the queue and yields them, until the queue becomes empty
+// (or the iteration is stopped). Elements that have not been yielded
+// remain in the queue. The queue must not be modified by the loop
+// body; if it is, the iterator panics.
+func (cq *Queue[T]) Drain() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			el, ok := cq.PopFront()
+			if !ok {
+				return
+			}
+			mod := cq.mod
+			if !yield(el) {
+				return
+			}
+			if cq.mod != mod {
+				panic("Q modified during iteration")
+			}
+		}
+	}
+}
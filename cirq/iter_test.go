@@ -0,0 +1,128 @@
+package cirq
+
+import (
+	"strings"
+	"testing"
+)
+
+// mkWrapped returns a queue, holding elements 0 ... n-1, whose
+// contents wrap around the end of the slice.
+func mkWrapped(n int) *Queue[int] {
+	q := NewQueue[int](1, 1024)
+	for i := n/2 - 1; i >= 0; i-- {
+		q.PushFront(i)
+	}
+	for i := n / 2; i < n; i++ {
+		q.PushBack(i)
+	}
+	return q
+}
+
+func TestAll(t *testing.T) {
+	q := mkWrapped(100)
+	n := 0
+	for i, v := range q.All() {
+		if i != n || v != n {
+			t.Fatalf("Bad element %d: %d", i, v)
+		}
+		n++
+	}
+	if n != 100 {
+		t.Fatalf("Iterated %d != 100 elements", n)
+	}
+	// Early break and Set during iteration.
+	for i, v := range q.All() {
+		if i == 50 {
+			break
+		}
+		q.Set(i, v*2)
+	}
+	for i := range 100 {
+		if v := q.At(i); (i < 50 && v != i*2) || (i >= 50 && v != i) {
+			t.Fatalf("Bad element %d: %d", i, v)
+		}
+	}
+}
+
+func TestBackward(t *testing.T) {
+	q := mkWrapped(100)
+	n := 100
+	for i, v := range q.Backward() {
+		n--
+		if i != n || v != n {
+			t.Fatalf("Bad element %d: %d", i, v)
+		}
+	}
+	if n != 0 {
+		t.Fatalf("Iterated %d != 100 elements", 100-n)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	q := mkWrapped(100)
+	n := 0
+	for v := range q.Drain() {
+		if v != n {
+			t.Fatalf("Bad element %d != %d", v, n)
+		}
+		n++
+		if n == 60 {
+			break
+		}
+	}
+	if q.Len() != 40 || q.MustPeekFront() != 60 {
+		t.Fatalf("Bad Q after drain: L=%d", q.Len())
+	}
+	for range q.Drain() {
+	}
+	if !q.Empty() {
+		t.Fatal("Q not empty after drain")
+	}
+}
+
+func TestIterModified(t *testing.T) {
+	mods := map[string]func(q *Queue[int]){
+		"PushBack":  func(q *Queue[int]) { q.PushBack(0) },
+		"PushFront": func(q *Queue[int]) { q.PushFront(0) },
+		"PopBack":   func(q *Queue[int]) { q.PopBack() },
+		"PopFront":  func(q *Queue[int]) { q.PopFront() },
+		"InsertAt":  func(q *Queue[int]) { q.InsertAt(5, 0) },
+		"RemoveAt":  func(q *Queue[int]) { q.RemoveAt(5) },
+		"Compact":   func(q *Queue[int]) { q.Compact(1024) },
+	}
+	iters := map[string]func(q *Queue[int], f func()){
+		"All": func(q *Queue[int], f func()) {
+			for range q.All() {
+				f()
+			}
+		},
+		"Backward": func(q *Queue[int], f func()) {
+			for range q.Backward() {
+				f()
+			}
+		},
+		"Drain": func(q *Queue[int], f func()) {
+			for range q.Drain() {
+				f()
+			}
+		},
+	}
+	for in, it := range iters {
+		for mn, mod := range mods {
+			func() {
+				defer func() {
+					x := recover()
+					if x == nil {
+						t.Fatalf("%s/%s: no panic", in, mn)
+					}
+					if !strings.HasPrefix(x.(string),
+						"Q modified") {
+						panic(x)
+					}
+				}()
+				q := mkWrapped(10)
+				it(q, func() { mod(q) })
+			}()
+		}
+	}
+}
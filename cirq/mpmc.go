@@ -0,0 +1,187 @@
+// Copyright (c) 2014, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package cirq
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// mpmcSlot is an element slot of an MPMC queue.
+type mpmcSlot[T any] struct {
+	seq atomic.Uint64 /* slot sequence number */
+	el  T
+}
+
+// MPMC is a bounded, lock-free, multi-producer / multi-consumer FIFO
+// queue. Any number of goroutines may push elements to it, and pop
+// elements from it, concurrently.
+//
+// It is implemented as an array of slots, each with its own sequence
+// number, indexed by free running head and tail indexes (this is
+// Dmitry Vyukov's bounded MPMC queue algorithm). The sequence number
+// of a slot tells if it is ready to be written by a producer, or read
+// by a consumer, for the current round. Producers (consumers) claim
+// slots by atomically advancing the tail (head) index. Like SPSC, MPMC
+// is allocated once, with a fixed size, and never grows.
+type MPMC[T any] struct {
+	_    [cacheLine]byte
+	tail atomic.Uint64 /* end index */
+	_    [cacheLine - 8]byte
+	head atomic.Uint64 /* start index */
+	_    [cacheLine - 8]byte
+	clsd atomic.Bool   /* queue closed */
+	sz   uint64        /* queue size */
+	m    uint64        /* queue mask (sz - 1) */
+	b    []mpmcSlot[T] /* buffer */
+	_    [cacheLine]byte
+}
+
+// NewMPMC creates and returns a new multi-producer / multi-consumer
+// queue, with space for sz elements (rounded up to a power of 2, and
+// no less than 2). It panics if sz is negative, or too large.
+func NewMPMC[T any](sz int) *MPMC[T] {
+	if sz >= 0 && sz < 2 {
+		sz = 2
+	}
+	usz, _, err := sizes(sz, sz)
+	if err != nil {
+		panic(err.Error())
+	}
+	q := &MPMC[T]{
+		sz: uint64(usz), m: uint64(usz) - 1,
+		b: make([]mpmcSlot[T], usz),
+	}
+	for i := range q.b {
+		q.b[i].seq.Store(uint64(i))
+	}
+	return q
+}
+
+// Cap returns the capacity of the queue.
+func (q *MPMC[T]) Cap() int {
+	return int(q.sz)
+}
+
+// Len returns the number of elements waiting in the queue. If called
+// concurrently with pushes or pops, the result is approximate.
+func (q *MPMC[T]) Len() int {
+	h := q.head.Load()
+	if d := int64(q.tail.Load() - h); d > 0 {
+		return int(d)
+	}
+	return 0
+}
+
+// TryPush adds element el to the back of the queue. Returns ok ==
+// false if the queue was full (unable to push element), ok == true
+// otherwise.
+func (q *MPMC[T]) TryPush(el T) (ok bool) {
+	pos := q.tail.Load()
+	for {
+		sl := &q.b[pos&q.m]
+		seq := sl.seq.Load()
+		switch d := int64(seq - pos); {
+		case d == 0:
+			if q.tail.CompareAndSwap(pos, pos+1) {
+				sl.el = el
+				sl.seq.Store(pos + 1)
+				return true
+			}
+			pos = q.tail.Load()
+		case d < 0:
+			// Slot not yet consumed for the previous round.
+			return false
+		default:
+			// Another producer claimed the slot.
+			pos = q.tail.Load()
+		}
+	}
+}
+
+// TryPop removes the front element from the queue and returns
+// it. Returns ok == false if the queue was empty (unable to pop
+// element), ok == true otherwise.
+func (q *MPMC[T]) TryPop() (el T, ok bool) {
+	var zero T
+	pos := q.head.Load()
+	for {
+		sl := &q.b[pos&q.m]
+		seq := sl.seq.Load()
+		switch d := int64(seq - (pos + 1)); {
+		case d == 0:
+			if q.head.CompareAndSwap(pos, pos+1) {
+				el = sl.el
+				sl.el = zero
+				sl.seq.Store(pos + q.sz)
+				return el, true
+			}
+			pos = q.head.Load()
+		case d < 0:
+			// Slot not yet filled for this round.
+			return zero, false
+		default:
+			// Another consumer claimed the slot.
+			pos = q.head.Load()
+		}
+	}
+}
+
+// Close marks the queue as closed. After Close, Push fails with
+// ErrClosed, while Pop succeeds until the queue is drained, and fails
+// with ErrClosed afterwards. Close should be called after all
+// producers have stopped pushing; elements pushed concurrently with
+// Close may not be seen by blocked consumers. It is ok to call Close
+// multiple times.
+func (q *MPMC[T]) Close() {
+	q.clsd.Store(true)
+}
+
+// Push adds element el to the back of the queue. If the queue is
+// full, Push waits (spinning, yielding, and sleeping for increasing
+// intervals) until space becomes available, or ctx is done. Returns
+// nil if the element was added, ErrClosed if the queue is closed, or
+// ctx.Err().
+func (q *MPMC[T]) Push(ctx context.Context, el T) error {
+	var bo backoff
+	for {
+		if q.clsd.Load() {
+			return ErrClosed
+		}
+		if q.TryPush(el) {
+			return nil
+		}
+		if err := bo.wait(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// Pop removes the front element from the queue and returns it. If the
+// queue is empty, Pop waits (spinning, yielding, and sleeping for
+// increasing intervals) until an element becomes available, the queue
+// is closed, or ctx is done. Returns ErrClosed if the queue is closed
+// and empty, or ctx.Err().
+func (q *MPMC[T]) Pop(ctx context.Context) (el T, err error) {
+	var bo backoff
+	for {
+		var ok bool
+		if el, ok = q.TryPop(); ok {
+			return el, nil
+		}
+		if q.clsd.Load() {
+			// Re-check: elements may have been pushed
+			// before the queue was closed.
+			if el, ok = q.TryPop(); ok {
+				return el, nil
+			}
+			return el, ErrClosed
+		}
+		if err = bo.wait(ctx); err != nil {
+			return el, err
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package cirq
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMPMCTry(t *testing.T) {
+	for _, sz := range []int{0, 1} {
+		if q := NewMPMC[int](sz); q.Cap() != 2 {
+			t.Fatalf("Q(%d) cap %d != 2", sz, q.Cap())
+		}
+	}
+	q := NewMPMC[int](8)
+	for j := 0; j < 3; j++ {
+		for i := 0; i < 8; i++ {
+			if !q.TryPush(i) {
+				t.Fatalf("Cannot push %d", i)
+			}
+		}
+		if q.TryPush(8) {
+			t.Fatal("Push to full Q")
+		}
+		if q.Len() != 8 {
+			t.Fatalf("Q len %d != 8", q.Len())
+		}
+		for i := 0; i < 8; i++ {
+			if v, ok := q.TryPop(); !ok || v != i {
+				t.Fatalf("Pop %d: %d, %v", i, v, ok)
+			}
+		}
+		if _, ok := q.TryPop(); ok {
+			t.Fatal("Pop from empty Q")
+		}
+	}
+}
+
+func TestMPMCCtx(t *testing.T) {
+	q := NewMPMC[int](2)
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+	if _, err := q.Pop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Pop from empty Q: %v", err)
+	}
+	q.TryPush(0)
+	q.TryPush(1)
+	if err := q.Push(ctx, 2); err != context.DeadlineExceeded {
+		t.Fatalf("Push to full Q: %v", err)
+	}
+	q.Close()
+	if err := q.Push(ctx, 2); err != ErrClosed {
+		t.Fatalf("Push to closed Q: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if v, err := q.Pop(ctx); err != nil || v != i {
+			t.Fatalf("Pop from closed Q: %d, %v", v, err)
+		}
+	}
+	if _, err := q.Pop(ctx); err != ErrClosed {
+		t.Fatalf("Pop from closed, empty Q: %v", err)
+	}
+}
+
+// TestMPMCStress checks, with multiple concurrent producers and
+// consumers, that every element pushed is popped exactly once, and
+// that each consumer sees the elements of each producer in the order
+// they were pushed (as a linearizable FIFO queue must). Run with:
+//
+//     go test -race -run=MPMCStress
+func TestMPMCStress(t *testing.T) {
+	nProd, nCons, nEl := 4, 4, 20000
+	if testing.Short() {
+		nEl = 2000
+	}
+	type el struct{ p, i int }
+	q := NewMPMC[el](16)
+	ctx := context.Background()
+
+	var wgP sync.WaitGroup
+	for p := 0; p < nProd; p++ {
+		wgP.Add(1)
+		go func(p int) {
+			defer wgP.Done()
+			for i := 0; i < nEl; i++ {
+				if i%2 == 0 {
+					for !q.TryPush(el{p, i}) {
+						runtime.Gosched()
+					}
+					continue
+				}
+				if err := q.Push(ctx, el{p, i}); err != nil {
+					t.Errorf("Cannot push: %v", err)
+					return
+				}
+			}
+		}(p)
+	}
+	go func() {
+		wgP.Wait()
+		q.Close()
+	}()
+
+	seen := make([][]int, nCons)
+	var wgC sync.WaitGroup
+	for c := 0; c < nCons; c++ {
+		wgC.Add(1)
+		go func(c int) {
+			defer wgC.Done()
+			last := make([]int, nProd)
+			for i := range last {
+				last[i] = -1
+			}
+			for {
+				e, err := q.Pop(ctx)
+				if err == ErrClosed {
+					return
+				}
+				if err != nil {
+					t.Errorf("Cannot pop: %v", err)
+					return
+				}
+				if e.i <= last[e.p] {
+					t.Errorf("Consumer %d: producer %d: "+
+						"%d after %d", c, e.p, e.i, last[e.p])
+					return
+				}
+				last[e.p] = e.i
+				seen[c] = append(seen[c], e.p*nEl+e.i)
+			}
+		}(c)
+	}
+	wgC.Wait()
+
+	cnt := make([]int, nProd*nEl)
+	for _, s := range seen {
+		for _, v := range s {
+			cnt[v]++
+		}
+	}
+	for v, n := range cnt {
+		if n != 1 {
+			t.Fatalf("Element %d/%d popped %d times",
+				v/nEl, v%nEl, n)
+		}
+	}
+}
+
+// Compare the MPMC queue with a buffered channel and a mutex-protected
+// Queue, with multiple producers and consumers.
+
+const mpmcSz = 1024
+
+func BenchmarkMPMC(b *testing.B) {
+	q := NewMPMC[int](mpmcSz)
+	ctx := context.Background()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			q.Push(ctx, 0)
+			q.Pop(ctx)
+		}
+	})
+}
+
+func BenchmarkMPMCChan(b *testing.B) {
+	c := make(chan int, mpmcSz)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c <- 0
+			<-c
+		}
+	})
+}
+
+func BenchmarkMPMCMutex(b *testing.B) {
+	var mu sync.Mutex
+	q := NewQueue[int](mpmcSz, mpmcSz)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			mu.Lock()
+			q.PushBack(0)
+			mu.Unlock()
+			mu.Lock()
+			q.PopFront()
+			mu.Unlock()
+		}
+	})
+}
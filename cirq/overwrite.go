@@ -0,0 +1,93 @@
+// Copyright (c) 2014, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package cirq
+
+// SetOverwrite enables (on == true) or disables (on == false) the
+// overwrite mode of the queue (by default disabled). In overwrite
+// mode, pushing to a full queue does not fail. Instead, elements are
+// dropped from the opposite end of the queue to make room for the
+// pushed ones. This allows the queue to be used as a bounded history
+// buffer (keeping the last MaxCap elements pushed). Overwrite mode
+// affects PushBack, PushFront, PushBackSlice and PushFrontSlice. It
+// does not affect InsertAt, which fails if the queue is full.
+func (cq *Queue[T]) SetOverwrite(on bool) {
+	cq.ovr = on
+}
+
+// Overwrite tests if overwrite mode is enabled.
+func (cq *Queue[T]) Overwrite() bool {
+	return cq.ovr
+}
+
+// Dropped returns the number of elements dropped from the queue, in
+// order to make room for new ones, since it was created. It counts
+// elements dropped in overwrite mode, or by PushBackEvict and
+// PushFrontEvict.
+func (cq *Queue[T]) Dropped() uint64 {
+	return cq.drops
+}
+
+// dropFront drops the front element of the queue. The queue must not
+// be empty.
+func (cq *Queue[T]) dropFront() {
+	var zero T
+	cq.b[cq.s&cq.m] = zero
+	cq.s++
+	cq.drops++
+}
+
+// dropBack drops the back element of the queue. The queue must not
+// be empty.
+func (cq *Queue[T]) dropBack() {
+	var zero T
+	cq.e--
+	cq.b[cq.e&cq.m] = zero
+	cq.drops++
+}
+
+// PushBackEvict adds element "el" to the back (tail) of the queue. If
+// the queue is full, the front element is removed to make room for
+// "el", and is returned as "ev" with evicted == true. Otherwise
+// evicted is false. PushBackEvict works the same regardless of the
+// queue's overwrite mode.
+func (cq *Queue[T]) PushBackEvict(el T) (ev T, evicted bool) {
+	if cq.shr {
+		cq.shrink()
+	}
+	if cq.e-cq.s == cq.maxSz {
+		ev = cq.b[cq.s&cq.m]
+		cq.dropFront()
+		evicted = true
+	} else if cq.e-cq.s == cq.sz {
+		cq.resize(cq.sz << 1)
+	}
+	cq.b[cq.e&cq.m] = el
+	cq.e++
+	cq.mod++
+	return ev, evicted
+}
+
+// PushFrontEvict adds element "el" to the front (head) of the
+// queue. If the queue is full, the back element is removed to make
+// room for "el", and is returned as "ev" with evicted ==
+// true. Otherwise evicted is false. PushFrontEvict works the same
+// regardless of the queue's overwrite mode.
+func (cq *Queue[T]) PushFrontEvict(el T) (ev T, evicted bool) {
+	if cq.shr {
+		cq.shrink()
+	}
+	if cq.e-cq.s == cq.maxSz {
+		ev = cq.b[(cq.e-1)&cq.m]
+		cq.dropBack()
+		evicted = true
+	} else if cq.e-cq.s == cq.sz {
+		cq.resize(cq.sz << 1)
+	}
+	cq.s--
+	cq.mod++
+	cq.b[cq.s&cq.m] = el
+	return ev, evicted
+}
@@ -0,0 +1,76 @@
+package cirq
+
+import "testing"
+
+func TestOverwrite(t *testing.T) {
+	q := NewQueue[int](1, 8)
+	q.SetOverwrite(true)
+	for i := 0; i < 20; i++ {
+		if !q.PushBack(i) {
+			t.Fatalf("Cannot push %d", i)
+		}
+	}
+	checkSeq(t, q, seq(12, 8))
+	if q.Dropped() != 12 {
+		t.Fatalf("Dropped %d != 12", q.Dropped())
+	}
+	for i := 11; i >= 8; i-- {
+		q.PushFront(i)
+	}
+	checkSeq(t, q, seq(8, 8))
+	if q.Dropped() != 16 {
+		t.Fatalf("Dropped %d != 16", q.Dropped())
+	}
+	q.SetOverwrite(false)
+	if q.PushBack(0) || q.PushFront(0) {
+		t.Fatal("Push to full Q")
+	}
+}
+
+func TestEvict(t *testing.T) {
+	q := NewQueue[int](1, 4)
+	for i := 0; i < 4; i++ {
+		if _, ev := q.PushBackEvict(i); ev {
+			t.Fatalf("Push %d evicted", i)
+		}
+	}
+	for i := 4; i < 8; i++ {
+		v, ev := q.PushBackEvict(i)
+		if !ev || v != i-4 {
+			t.Fatalf("Push %d evicted: %d, %v", i, v, ev)
+		}
+	}
+	checkSeq(t, q, seq(4, 4))
+	v, ev := q.PushFrontEvict(3)
+	if !ev || v != 7 {
+		t.Fatalf("PushFront evicted: %d, %v", v, ev)
+	}
+	checkSeq(t, q, seq(3, 4))
+	if q.Dropped() != 5 {
+		t.Fatalf("Dropped %d != 5", q.Dropped())
+	}
+}
+
+func TestOverwriteSlice(t *testing.T) {
+	q := NewQueue[int](1, 16)
+	q.SetOverwrite(true)
+	q.PushBackSlice(seq(0, 10))
+	if n := q.PushBackSlice(seq(10, 10)); n != 10 {
+		t.Fatalf("PushBackSlice: %d != 10", n)
+	}
+	checkSeq(t, q, seq(4, 16))
+	if n := q.PushBackSlice(seq(100, 40)); n != 40 {
+		t.Fatalf("PushBackSlice: %d != 40", n)
+	}
+	checkSeq(t, q, seq(124, 16))
+	if n := q.PushFrontSlice(seq(0, 20)); n != 20 {
+		t.Fatalf("PushFrontSlice: %d != 20", n)
+	}
+	checkSeq(t, q, seq(0, 16))
+	q.PopBackInto(make([]int, 6))
+	q.PushFrontSlice(seq(-8, 8))
+	checkSeq(t, q, seq(-8, 16))
+	if q.Dropped() != 4+40+20+2 {
+		t.Fatalf("Dropped %d != %d", q.Dropped(), 4+40+20+2)
+	}
+}
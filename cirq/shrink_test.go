@@ -0,0 +1,84 @@
+package cirq
+
+import "testing"
+
+func TestShrink(t *testing.T) {
+	q, err := NewQueueOpts[int](4, 1024, &Options{Shrink: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.PushBackSlice(seq(0, 1000))
+	if q.Cap() != 1024 {
+		t.Fatalf("Q cap %d != 1024", q.Cap())
+	}
+	// Cap is halved when Len drops below Cap/4.
+	for q.Len() > 256 {
+		q.PopFront()
+		if q.Cap() != 1024 {
+			t.Fatalf("Q len %d: cap %d != 1024", q.Len(), q.Cap())
+		}
+	}
+	q.PopFront()
+	if q.Len() != 255 || q.Cap() != 512 {
+		t.Fatalf("Q len %d: cap %d != 512", q.Len(), q.Cap())
+	}
+	checkSeq(t, q, seq(745, 255))
+	q.PopBackInto(make([]int, 250))
+	if q.Len() != 5 || q.Cap() != 16 {
+		t.Fatalf("Q len %d: cap %d != 16", q.Len(), q.Cap())
+	}
+	q.PopBack()
+	q.RemoveAt(0)
+	checkSeq(t, q, seq(746, 3))
+	if q.Cap() != 8 {
+		t.Fatalf("Q cap %d != 8", q.Cap())
+	}
+	for !q.Empty() {
+		q.PopFront()
+	}
+	if q.Cap() != 4 {
+		t.Fatalf("Q cap %d != 4 (floor)", q.Cap())
+	}
+}
+
+func TestShrinkMinCap(t *testing.T) {
+	q, err := NewQueueOpts[int](1, 64,
+		&Options{Shrink: true, ShrinkDiv: 8, MinCap: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.PushBackSlice(seq(0, 64))
+	for !q.Empty() {
+		c := q.Cap()
+		q.PopFront()
+		if q.Cap() != c && q.Len() >= c/8 {
+			t.Fatalf("Q len %d: early shrink to %d", q.Len(), q.Cap())
+		}
+	}
+	if q.Cap() != 16 {
+		t.Fatalf("Q cap %d != 16", q.Cap())
+	}
+}
+
+func TestOptsInvalid(t *testing.T) {
+	for _, o := range []*Options{
+		{ShrinkDiv: 2},
+		{MinCap: 2048},
+		{MinCap: -1},
+	} {
+		if _, err := NewQueueOpts[int](1, 1024, o); err != ErrOptions {
+			t.Fatalf("Options %+v: %v != %v", *o, err, ErrOptions)
+		}
+	}
+	if _, err := NewQueueOpts[int](4, 2, nil); err != ErrSize {
+		t.Fatalf("Bad size: %v != %v", err, ErrSize)
+	}
+	q, err := NewQueueOpts[int](1, 32, &Options{MinCap: 3})
+	if err != nil || q.minSz != 4 {
+		t.Fatalf("MinCap not rounded up: %v", err)
+	}
+	q, err = NewQueueOpts[int](1, 2, &Options{Overwrite: true})
+	if err != nil || !q.Overwrite() {
+		t.Fatalf("Overwrite option not set: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2014, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package cirq
+
+// appendElems appends the elements of the queue, from front to back,
+// to dst, and returns the extended slice. The elements are stored in
+// the queue slice in one part, or in two (if they wrap around its
+// end).
+func (cq *Queue[T]) appendElems(dst []T) []T {
+	si, n := cq.s&cq.m, cq.e-cq.s
+	if si+n <= cq.sz {
+		return append(dst, cq.b[si:si+n]...)
+	}
+	dst = append(dst, cq.b[si:]...)
+	return append(dst, cq.b[:si+n-cq.sz]...)
+}
+
+// Clone returns a copy of the queue. The copy has the same elements,
+// capacity, maximum capacity, and options as the original. Elements
+// are copied by assignment (i.e. if they are pointers, the copy
+// points to the same objects).
+func (cq *Queue[T]) Clone() *Queue[T] {
+	if cq.shr {
+		cq.shrink()
+	}
+	c := *cq
+	c.b = make([]T, len(cq.b))
+	copy(c.b, cq.b)
+	return &c
+}
+
+// Slice returns a new slice holding the elements of the queue, from
+// front (head) to back (tail).
+func (cq *Queue[T]) Slice() []T {
+	return cq.appendElems(make([]T, 0, cq.e-cq.s))
+}
+
+// AppendTo appends the elements of the queue, from front (head) to
+// back (tail), to dst and returns the extended slice.
+func (cq *Queue[T]) AppendTo(dst []T) []T {
+	return cq.appendElems(dst)
+}
+
+// Equal tests if the queue and other hold the same elements, in the
+// same order, according to the comparison function eq. Capacities and
+// options are not compared.
+func (cq *Queue[T]) Equal(other *Queue[T], eq func(a, b T) bool) bool {
+	if cq.e-cq.s != other.e-other.s {
+		return false
+	}
+	for i, n := uint(0), cq.e-cq.s; i < n; i++ {
+		if !eq(cq.b[(cq.s+i)&cq.m], other.b[(other.s+i)&other.m]) {
+			return false
+		}
+	}
+	return true
+}
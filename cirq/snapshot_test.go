@@ -0,0 +1,63 @@
+package cirq
+
+import (
+	"slices"
+	"testing"
+)
+
+func eqInt(a, b int) bool { return a == b }
+
+func TestSlice(t *testing.T) {
+	q := NewQueue[int](4, 16)
+	if s := q.Slice(); len(s) != 0 {
+		t.Fatalf("Slice of empty Q: %v", s)
+	}
+	q = mkWrapped(100)
+	if s := q.Slice(); !slices.Equal(s, seq(0, 100)) {
+		t.Fatalf("Slice: %v", s)
+	}
+	s := q.AppendTo(seq(-5, 5))
+	if !slices.Equal(s, seq(-5, 105)) {
+		t.Fatalf("AppendTo: %v", s)
+	}
+	checkSeq(t, q, seq(0, 100))
+	// Full Q, wrapped.
+	q = NewQueue[int](8, 8)
+	q.PushBackSlice(seq(0, 5))
+	q.PopFrontInto(make([]int, 5))
+	q.PushBackSlice(seq(0, 8))
+	if s := q.Slice(); !slices.Equal(s, seq(0, 8)) {
+		t.Fatalf("Slice of full Q: %v", s)
+	}
+}
+
+func TestCloneEqual(t *testing.T) {
+	q := mkWrapped(100)
+	c := q.Clone()
+	if c.Cap() != q.Cap() || c.MaxCap() != q.MaxCap() {
+		t.Fatalf("Clone cap/maxCap %d/%d != %d/%d",
+			c.Cap(), c.MaxCap(), q.Cap(), q.MaxCap())
+	}
+	if !c.Equal(q, eqInt) || !q.Equal(c, eqInt) {
+		t.Fatal("Clone not equal to original")
+	}
+	checkSeq(t, c, seq(0, 100))
+	c.Set(50, -1)
+	if q.At(50) != 50 {
+		t.Fatal("Clone shares storage with original")
+	}
+	if c.Equal(q, eqInt) {
+		t.Fatal("Modified clone equal to original")
+	}
+	c.Set(50, 50)
+	c.PopBack()
+	if c.Equal(q, eqInt) {
+		t.Fatal("Shorter clone equal to original")
+	}
+	// Same elements, different layout and capacity.
+	o := NewQueue[int](128, 128)
+	o.PushBackSlice(seq(0, 100))
+	if !o.Equal(q, eqInt) {
+		t.Fatal("Queues with same elements not equal")
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright (c) 2014, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package cirq
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// cacheLine is the (assumed) size of a CPU cache line. Fields written
+// by different goroutines are padded to separate cache lines, to
+// avoid false sharing.
+const cacheLine = 64
+
+// SPSC is a bounded, lock-free, single-producer / single-consumer
+// FIFO queue. Exactly one goroutine may push elements to it (the
+// producer), and exactly one goroutine may pop elements from it (the
+// consumer). The producer and the consumer may be different
+// goroutines, and may operate on the queue concurrently, without
+// locking.
+//
+// Like Queue, SPSC is implemented with a slice and free running
+// indexes. Unlike Queue, it is allocated once, with a fixed size
+// (rounded up to a power of 2), and never grows. The head index is
+// written only by the consumer, and the tail index only by the
+// producer. Each side keeps a cached copy of the other side's index,
+// which it refreshes only when the queue appears full (or empty).
+type SPSC[T any] struct {
+	_     [cacheLine]byte
+	tail  atomic.Uint64 /* end index, written by producer */
+	headC uint64        /* producer's copy of head */
+	pClsd bool          /* closed, producer's copy */
+	_     [cacheLine - 17]byte
+	head  atomic.Uint64 /* start index, written by consumer */
+	tailC uint64        /* consumer's copy of tail */
+	_     [cacheLine - 16]byte
+	clsd  atomic.Bool /* closed by producer */
+	sz    uint64      /* queue size */
+	m     uint64      /* queue mask (sz - 1) */
+	b     []T         /* buffer */
+	_     [cacheLine]byte
+}
+
+// NewSPSC creates and returns a new single-producer /
+// single-consumer queue, with space for sz elements (rounded up to a
+// power of 2, and no less than 1). It panics if sz is negative, or
+// too large.
+func NewSPSC[T any](sz int) *SPSC[T] {
+	if sz == 0 {
+		sz = 1
+	}
+	usz, _, err := sizes(sz, sz)
+	if err != nil {
+		panic(err.Error())
+	}
+	return &SPSC[T]{
+		sz: uint64(usz), m: uint64(usz) - 1,
+		b: make([]T, usz),
+	}
+}
+
+// Cap returns the capacity of the queue.
+func (q *SPSC[T]) Cap() int {
+	return int(q.sz)
+}
+
+// Len returns the number of elements waiting in the queue. If called
+// concurrently with pushes or pops, the result is approximate.
+func (q *SPSC[T]) Len() int {
+	h := q.head.Load()
+	return int(q.tail.Load() - h)
+}
+
+// TryPush adds element el to the back of the queue. Returns ok ==
+// false if the queue was full (unable to push element), ok == true
+// otherwise. It must only be called by the producer.
+func (q *SPSC[T]) TryPush(el T) (ok bool) {
+	t := q.tail.Load()
+	if t-q.headC == q.sz {
+		q.headC = q.head.Load()
+		if t-q.headC == q.sz {
+			return false
+		}
+	}
+	q.b[t&q.m] = el
+	q.tail.Store(t + 1)
+	return true
+}
+
+// TryPop removes the front element from the queue and returns
+// it. Returns ok == false if the queue was empty (unable to pop
+// element), ok == true otherwise. It must only be called by the
+// consumer.
+func (q *SPSC[T]) TryPop() (el T, ok bool) {
+	var zero T
+	h := q.head.Load()
+	if h == q.tailC {
+		q.tailC = q.tail.Load()
+		if h == q.tailC {
+			return zero, false
+		}
+	}
+	el = q.b[h&q.m]
+	q.b[h&q.m] = zero
+	q.head.Store(h + 1)
+	return el, true
+}
+
+// PushSlice adds the elements of s to the back of the queue, in
+// order. If the queue cannot accomodate all of them, the first n
+// elements of s are added. Returns the number of elements added. It
+// must only be called by the producer.
+func (q *SPSC[T]) PushSlice(s []T) int {
+	t := q.tail.Load()
+	if uint64(len(s)) > q.sz-(t-q.headC) {
+		q.headC = q.head.Load()
+	}
+	n := q.sz - (t - q.headC)
+	if uint64(len(s)) < n {
+		n = uint64(len(s))
+	}
+	if n == 0 {
+		return 0
+	}
+	k := copy(q.b[t&q.m:], s[:n])
+	copy(q.b, s[k:n])
+	q.tail.Store(t + n)
+	return int(n)
+}
+
+// PopSlice removes up to len(dst) elements from the front of the
+// queue and stores them, in order, in dst. Returns the number of
+// elements removed. It must only be called by the consumer.
+func (q *SPSC[T]) PopSlice(dst []T) int {
+	h := q.head.Load()
+	if uint64(len(dst)) > q.tailC-h {
+		q.tailC = q.tail.Load()
+	}
+	n := q.tailC - h
+	if uint64(len(dst)) < n {
+		n = uint64(len(dst))
+	}
+	if n == 0 {
+		return 0
+	}
+	si := h & q.m
+	k := copy(dst[:n], q.b[si:])
+	copy(dst[k:n], q.b)
+	if si+n <= q.sz {
+		clear(q.b[si : si+n])
+	} else {
+		clear(q.b[si:])
+		clear(q.b[:si+n-q.sz])
+	}
+	q.head.Store(h + n)
+	return int(n)
+}
+
+// Close marks the queue as closed. After Close, Push fails with
+// ErrClosed, while Pop succeeds until the queue is drained, and fails
+// with ErrClosed afterwards. It must only be called by the producer.
+func (q *SPSC[T]) Close() {
+	q.pClsd = true
+	q.clsd.Store(true)
+}
+
+// Push adds element el to the back of the queue. If the queue is
+// full, Push waits (spinning, yielding, and sleeping for increasing
+// intervals) until space becomes available, or ctx is done. Returns
+// nil if the element was added, ErrClosed if the queue is closed, or
+// ctx.Err(). It must only be called by the producer.
+func (q *SPSC[T]) Push(ctx context.Context, el T) error {
+	if q.pClsd {
+		return ErrClosed
+	}
+	var bo backoff
+	for !q.TryPush(el) {
+		if err := bo.wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pop removes the front element from the queue and returns it. If the
+// queue is empty, Pop waits (spinning, yielding, and sleeping for
+// increasing intervals) until an element becomes available, the queue
+// is closed, or ctx is done. Returns ErrClosed if the queue is closed
+// and empty, or ctx.Err(). It must only be called by the consumer.
+func (q *SPSC[T]) Pop(ctx context.Context) (el T, err error) {
+	var bo backoff
+	for {
+		var ok bool
+		if el, ok = q.TryPop(); ok {
+			return el, nil
+		}
+		if q.clsd.Load() {
+			// Re-check: elements may have been pushed
+			// before the queue was closed.
+			if el, ok = q.TryPop(); ok {
+				return el, nil
+			}
+			return el, ErrClosed
+		}
+		if err = bo.wait(ctx); err != nil {
+			return el, err
+		}
+	}
+}
+
+// Parameters for the backoff waiting strategy.
+const (
+	boSpin     = 64                   /* # of busy-spin rounds */
+	boYield    = 128                  /* # of rounds before sleeping */
+	boMinSleep = 1 * time.Microsecond /* initial sleep duration */
+	boMaxSleep = 1 * time.Millisecond /* max sleep duration */
+)
+
+// spin is true if busy-spinning makes sense (i.e. if there is more
+// than one CPU to run the goroutine we are waiting for).
+var spin = runtime.NumCPU() > 1
+
+// backoff implements the waiting strategy used by the blocking
+// operations of the lock-free queues: Busy-spin for a few rounds (if
+// there are more than one CPUs), then yield the processor for a few more, then sleep for
+// exponentially increasing durations (up to a maximum). The zero
+// value is ready to use.
+type backoff struct {
+	n int           /* # of rounds so far */
+	d time.Duration /* next sleep duration */
+}
+
+// wait waits for one round of the backoff strategy. Returns ctx.Err()
+// if ctx is done, nil otherwise.
+func (bo *backoff) wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	bo.n++
+	switch {
+	case bo.n <= boSpin && spin:
+	case bo.n <= boYield:
+		runtime.Gosched()
+	default:
+		if bo.d == 0 {
+			bo.d = boMinSleep
+		}
+		time.Sleep(bo.d)
+		if bo.d < boMaxSleep {
+			bo.d <<= 1
+		}
+	}
+	return nil
+}
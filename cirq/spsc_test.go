@@ -0,0 +1,190 @@
+package cirq
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSPSCTry(t *testing.T) {
+	if q := NewSPSC[int](0); q.Cap() != 1 || !q.TryPush(1) || q.TryPush(2) {
+		t.Fatalf("Q(0) cap %d != 1", q.Cap())
+	}
+	q := NewSPSC[int](5)
+	if q.Cap() != 8 {
+		t.Fatalf("Q cap %d != 8", q.Cap())
+	}
+	for j := 0; j < 3; j++ {
+		for i := 0; i < 8; i++ {
+			if !q.TryPush(i) {
+				t.Fatalf("Cannot push %d", i)
+			}
+		}
+		if q.TryPush(8) {
+			t.Fatal("Push to full Q")
+		}
+		for i := 0; i < 8; i++ {
+			if v, ok := q.TryPop(); !ok || v != i {
+				t.Fatalf("Pop %d: %d, %v", i, v, ok)
+			}
+		}
+		if _, ok := q.TryPop(); ok {
+			t.Fatal("Pop from empty Q")
+		}
+	}
+}
+
+func TestSPSCSlice(t *testing.T) {
+	q := NewSPSC[int](8)
+	q.PushSlice(seq(0, 5))
+	q.PopSlice(make([]int, 5))
+	// Now the contents wrap around
+	if n := q.PushSlice(seq(0, 10)); n != 8 {
+		t.Fatalf("PushSlice: %d != 8", n)
+	}
+	dst := make([]int, 10)
+	if n := q.PopSlice(dst); n != 8 {
+		t.Fatalf("PopSlice: %d != 8", n)
+	}
+	for i := 0; i < 8; i++ {
+		if dst[i] != i {
+			t.Fatalf("Bad element %d != %d", dst[i], i)
+		}
+	}
+	for i, v := range q.b {
+		if v != 0 {
+			t.Fatalf("Slot %d not cleared: %d", i, v)
+		}
+	}
+}
+
+func TestSPSCConcurrent(t *testing.T) {
+	const n = 100000
+	q := NewSPSC[int](64)
+	ctx := context.Background()
+	go func() {
+		buf := make([]int, 0, 16)
+		for i := 0; i < n; {
+			if i%3 == 0 {
+				// Batch push
+				buf = buf[:0]
+				for j := i; j < i+16 && j < n; j++ {
+					buf = append(buf, j)
+				}
+				if m := q.PushSlice(buf); m > 0 {
+					i += m
+					continue
+				}
+			}
+			if err := q.Push(ctx, i); err != nil {
+				t.Errorf("Cannot push %d: %v", i, err)
+				return
+			}
+			i++
+		}
+		q.Close()
+	}()
+	next := 0
+	buf := make([]int, 7)
+	for {
+		if next%5 == 0 {
+			m := q.PopSlice(buf)
+			for _, v := range buf[:m] {
+				if v != next {
+					t.Fatalf("Bad element %d != %d", v, next)
+				}
+				next++
+			}
+			if m > 0 {
+				continue
+			}
+		}
+		v, err := q.Pop(ctx)
+		if err == ErrClosed {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Cannot pop: %v", err)
+		}
+		if v != next {
+			t.Fatalf("Bad element %d != %d", v, next)
+		}
+		next++
+	}
+	if next != n {
+		t.Fatalf("Popped %d != %d elements", next, n)
+	}
+	if err := q.Push(ctx, 0); err != ErrClosed {
+		t.Fatalf("Push to closed Q: %v", err)
+	}
+}
+
+func TestSPSCCtx(t *testing.T) {
+	q := NewSPSC[int](1)
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+	if _, err := q.Pop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Pop from empty Q: %v", err)
+	}
+	q.TryPush(0)
+	if err := q.Push(ctx, 1); err != context.DeadlineExceeded {
+		t.Fatalf("Push to full Q: %v", err)
+	}
+}
+
+// Compare the SPSC queue with a buffered channel, with one producer
+// and one consumer goroutine.
+
+const spscSz = 1024
+
+func BenchmarkSPSC(b *testing.B) {
+	q := NewSPSC[int](spscSz)
+	ctx := context.Background()
+	go func() {
+		for i := 0; i < b.N; i++ {
+			q.Push(ctx, i)
+		}
+	}()
+	for i := 0; i < b.N; i++ {
+		q.Pop(ctx)
+	}
+}
+
+func BenchmarkSPSCBatch(b *testing.B) {
+	q := NewSPSC[int](spscSz)
+	ctx := context.Background()
+	go func() {
+		s := make([]int, 64)
+		for i := 0; i < b.N; {
+			m := min(len(s), b.N-i)
+			n := q.PushSlice(s[:m])
+			if n == 0 {
+				q.Push(ctx, i)
+				n = 1
+			}
+			i += n
+		}
+	}()
+	d := make([]int, 64)
+	for i := 0; i < b.N; {
+		n := q.PopSlice(d)
+		if n == 0 {
+			q.Pop(ctx)
+			n = 1
+		}
+		i += n
+	}
+}
+
+func BenchmarkSPSCChan(b *testing.B) {
+	c := make(chan int, spscSz)
+	go func() {
+		for i := 0; i < b.N; i++ {
+			c <- i
+		}
+	}()
+	for i := 0; i < b.N; i++ {
+		<-c
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright (c) 2014, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE file.
+
+package cirq
+
+import _ "embed"
+
+// Template is the source of the template (cirq.gox) used by the
+// cirqgen command (see cmd/cirqgen) to generate queue implementations
+// specialized to specific element data-types. It is embedded in the
+// package, so that cirqgen does not have to locate the package
+// sources.
+//
+//go:embed cirq.gox
+var Template string
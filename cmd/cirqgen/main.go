@@ -0,0 +1,297 @@
+// Copyright (c) 2016, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+// Command cirqgen generates circular queue implementations
+// specialized to specific element data-types, from the template in
+// package cirq (see cirq.Template). It replaces the cirq_gen.sh
+// script, and is meant to be used from go:generate directives, like:
+//
+//	//go:generate cirqgen -o intq.go -pkg mypkg -type intQ -new newIntQ -elem int
+//
+// The element type (-elem) can be any Go type expression. Types from
+// other packages can be qualified either by package name (e.g.
+// time.Duration), or by full import path (e.g. *net/http.Request, or
+// []github.com/user/repo/pkg.Type). In both cases, the respective
+// import is added to the generated file. The import path of a package
+// name is taken to be the name itself (as for standard library
+// packages like "time"), unless given explicitly with the -import
+// flag (e.g. -import yaml=gopkg.in/yaml.v3). The package name for a
+// full import path is taken from its last element, ignoring
+// major-version suffixes (e.g. "yaml" for gopkg.in/yaml.v3, or "pkg"
+// for github.com/user/pkg/v2).
+//
+// The template is parsed and the substitutions are made on its syntax
+// tree, before it is printed and formatted with go/format. The
+// template embedded in package cirq is used, unless another one is
+// given with the -template flag.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/npat-efault/gohacks/cirq"
+)
+
+// Placeholder identifiers used in the template.
+const (
+	phPackage = "__PACKAGE"
+	phQ       = "__Q"
+	phNewQ    = "__NewQ"
+	phElType  = "__ELTYPE"
+)
+
+// placeholder matches the placeholder identifiers in comments.
+var placeholder = regexp.MustCompile(`\b(` + phPackage + `|` + phQ + `|` +
+	phNewQ + `|` + phElType + `)\b`)
+
+// header is prepended to the generated files.
+const header = "// Code generated by cirqgen. DO NOT EDIT.\n\n"
+
+// config holds the generator parameters.
+type config struct {
+	pkg     string            /* package name */
+	qtype   string            /* queue type name */
+	newq    string            /* constructor name */
+	elType  string            /* element type expression */
+	imports map[string]string /* package name -> import path */
+}
+
+// pathQual matches package qualifiers given as full import paths
+// (e.g. "github.com/user/repo/pkg.Type").
+var pathQual = regexp.MustCompile(`((?:[\w.~-]+/)+[\w.~-]+)\.([\p{L}_][\p{L}\p{N}_]*)`)
+
+// majorVersion matches major-version suffixes of import paths.
+var majorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// pathPkg returns the package name for the import path: its last
+// element, skipping a major-version suffix (as in ".../pkg/v2"), and
+// up to the first dot (as in "gopkg.in/yaml.v3").
+func pathPkg(path string) string {
+	els := strings.Split(path, "/")
+	el := els[len(els)-1]
+	if majorVersion.MatchString(el) && len(els) > 1 {
+		el = els[len(els)-2]
+	}
+	el, _, _ = strings.Cut(el, ".")
+	return strings.ReplaceAll(el, "-", "_")
+}
+
+// elemType resolves the qualifiers in the element type expression
+// of cfg. It returns the element type, as a valid Go expression, and
+// the imports it requires (package name -> import path).
+func elemType(cfg *config) (string, map[string]string, error) {
+	imps := map[string]string{}
+	var err error
+	el := pathQual.ReplaceAllStringFunc(cfg.elType, func(s string) string {
+		m := pathQual.FindStringSubmatch(s)
+		path, name := m[1], m[2]
+		pkg := pathPkg(path)
+		if !token.IsIdentifier(pkg) {
+			err = fmt.Errorf("cannot determine package name for %q",
+				path)
+		}
+		if p, ok := imps[pkg]; ok && p != path {
+			err = fmt.Errorf("conflicting imports for %q: %q, %q",
+				pkg, p, path)
+		}
+		imps[pkg] = path
+		return pkg + "." + name
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	x, perr := parser.ParseExpr(el)
+	if perr != nil {
+		return "", nil, fmt.Errorf("invalid element type %q: %v",
+			cfg.elType, perr)
+	}
+	ast.Inspect(x, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		id, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if _, ok := imps[id.Name]; !ok {
+			path, ok := cfg.imports[id.Name]
+			if !ok {
+				path = id.Name
+			}
+			imps[id.Name] = path
+		}
+		return false
+	})
+	return el, imps, nil
+}
+
+// addImports adds import declarations for imps to the file f.
+func addImports(f *ast.File, imps map[string]string) {
+	if len(imps) == 0 {
+		return
+	}
+	names := make([]string, 0, len(imps))
+	for n := range imps {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return imps[names[i]] < imps[names[j]]
+	})
+	pos := f.Name.End()
+	gd := &ast.GenDecl{Tok: token.IMPORT, TokPos: pos}
+	if len(names) > 1 {
+		gd.Lparen, gd.Rparen = pos, pos
+	}
+	for _, n := range names {
+		path := imps[n]
+		is := &ast.ImportSpec{
+			Path: &ast.BasicLit{Kind: token.STRING,
+				Value: strconv.Quote(path), ValuePos: pos},
+		}
+		if path[strings.LastIndex(path, "/")+1:] != n {
+			is.Name = &ast.Ident{Name: n, NamePos: pos}
+		}
+		gd.Specs = append(gd.Specs, is)
+		f.Imports = append(f.Imports, is)
+	}
+	f.Decls = append([]ast.Decl{gd}, f.Decls...)
+}
+
+// generate generates the source of a queue implementation from the
+// template source tmpl.
+func generate(tmpl string, cfg *config) ([]byte, error) {
+	for _, id := range []string{cfg.pkg, cfg.qtype, cfg.newq} {
+		if !token.IsIdentifier(id) {
+			return nil, fmt.Errorf("invalid identifier %q", id)
+		}
+	}
+	el, imps, err := elemType(cfg)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "cirq.gox", tmpl, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template: %v", err)
+	}
+	if len(f.Imports) != 0 {
+		return nil, errors.New("template with imports not supported")
+	}
+	subst := map[string]string{
+		phPackage: cfg.pkg,
+		phQ:       cfg.qtype,
+		phNewQ:    cfg.newq,
+		// The element type is not an identifier; it is
+		// printed verbatim in place of one, and the result
+		// is re-parsed by format.Source.
+		phElType: el,
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			if s, ok := subst[id.Name]; ok {
+				id.Name = s
+			}
+		}
+		return true
+	})
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			c.Text = placeholder.ReplaceAllStringFunc(c.Text,
+				func(s string) string { return subst[s] })
+		}
+	}
+	addImports(f, imps)
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	pcfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent,
+		Tabwidth: 8}
+	if err := pcfg.Fprint(&buf, fset, f); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting output: %v", err)
+	}
+	return src, nil
+}
+
+// importFlag collects -import flag values.
+type importFlag map[string]string
+
+func (imf importFlag) String() string {
+	return fmt.Sprint(map[string]string(imf))
+}
+
+func (imf importFlag) Set(s string) error {
+	n, path, ok := strings.Cut(s, "=")
+	if !ok || !token.IsIdentifier(n) || path == "" {
+		return errors.New("must be of the form name=path")
+	}
+	imf[n] = path
+	return nil
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: cirqgen [flags]\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	cfg := config{imports: map[string]string{}}
+	out := flag.String("o", "", "name of the generated `file` (default stdout)")
+	tmplFile := flag.String("template", "", "template `file` (default: the one in package cirq)")
+	flag.StringVar(&cfg.pkg, "pkg", "", "package `name` for the generated file")
+	flag.StringVar(&cfg.qtype, "type", "", "`name` for the queue type")
+	flag.StringVar(&cfg.newq, "new", "", "`name` for the function returning a new queue")
+	flag.StringVar(&cfg.elType, "elem", "", "`type` of the queue elements")
+	flag.Var(importFlag(cfg.imports), "import", "import path for a package name (`name=path`), may be repeated")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 0 || cfg.pkg == "" || cfg.qtype == "" ||
+		cfg.newq == "" || cfg.elType == "" {
+		usage()
+	}
+
+	tmpl := cirq.Template
+	if *tmplFile != "" {
+		b, err := os.ReadFile(*tmplFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cirqgen: %v\n", err)
+			os.Exit(1)
+		}
+		tmpl = string(b)
+	}
+	src, err := generate(tmpl, &cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cirqgen: %v\n", err)
+		os.Exit(1)
+	}
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	// Output files generated by cirq_gen.sh were read-only.
+	os.Remove(*out)
+	if err := os.WriteFile(*out, src, 0444); err != nil {
+		fmt.Fprintf(os.Stderr, "cirqgen: %v\n", err)
+		os.Exit(1)
+	}
+}
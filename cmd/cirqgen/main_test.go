@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/npat-efault/gohacks/cirq"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var goldenTests = []struct {
+	name string
+	cfg  config
+}{
+	{"int", config{pkg: "cirq_test", qtype: "intCQ", newq: "newIntCQ",
+		elType: "int"}},
+	{"duration", config{pkg: "timeq", qtype: "DurationQ",
+		newq: "NewDurationQ", elType: "time.Duration"}},
+	{"request", config{pkg: "reqq", qtype: "reqQ", newq: "newReqQ",
+		elType: "*net/http.Request"}},
+	{"yaml", config{pkg: "yamlq", qtype: "nodeQ", newq: "newNodeQ",
+		elType:  "map[string][]yaml.Node",
+		imports: map[string]string{"yaml": "gopkg.in/yaml.v3"}}},
+	{"multi", config{pkg: "multiq", qtype: "Q", newq: "NewQ",
+		elType: "struct{ d time.Duration; v github.com/user/repo-x/v2.T }"}},
+}
+
+func TestGolden(t *testing.T) {
+	for _, tc := range goldenTests {
+		src, err := generate(cirq.Template, &tc.cfg)
+		if err != nil {
+			t.Errorf("%s: generate: %v", tc.name, err)
+			continue
+		}
+		golden := filepath.Join("testdata", tc.name+".golden")
+		if *update {
+			if err := os.WriteFile(golden, src, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(src, want) {
+			t.Errorf("%s: output differs from %s", tc.name, golden)
+		}
+	}
+}
+
+// TestIntQ checks that the generated int queue, used by the cirq
+// benchmarks, is up to date.
+func TestIntQ(t *testing.T) {
+	want, err := os.ReadFile("../../cirq/intq_gen_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.ReadFile(filepath.Join("testdata", "int.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, want) {
+		t.Errorf("cirq/intq_gen_test.go is out of date")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, cfg := range []config{
+		{pkg: "p", qtype: "q", newq: "newQ", elType: "map[int"},
+		{pkg: "p", qtype: "q", newq: "newQ", elType: "int; var x"},
+		{pkg: "p", qtype: "q", newq: "newQ",
+			elType: "map[a/x.T]b/x.T"},
+		{pkg: "p", qtype: "q-1", newq: "newQ", elType: "int"},
+		{pkg: "p", qtype: "q", newq: "", elType: "int"},
+		{pkg: "p", qtype: "q", newq: "newQ", elType: "a/1x.T"},
+	} {
+		if _, err := generate(cirq.Template, &cfg); err == nil {
+			t.Errorf("%+v: no error", cfg)
+		}
+	}
+}
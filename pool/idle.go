@@ -0,0 +1,133 @@
+package pool
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/npat-efault/gohacks/task"
+)
+
+// decay decays the high-water mark of the objects in use, to time
+// now. Must be called with p.mu held.
+func (p *Of[T]) decay(now int64) {
+	if now > p.dhwT {
+		p.dhw *= math.Exp2(-float64(now-p.dhwT) / float64(p.hl))
+		p.dhwT = now
+	}
+}
+
+// taken records that an object was handed out, at time now.
+func (p *Of[T]) taken(now int64) {
+	p.mu.Lock()
+	p.decay(now)
+	p.inUse++
+	if float64(p.inUse) > p.dhw {
+		p.dhw = float64(p.inUse)
+	}
+	p.mu.Unlock()
+}
+
+// returned records that an object was recycled, at time now, and
+// returns the current retention limit.
+func (p *Of[T]) returned(now int64) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.inUse > 0 {
+		p.inUse--
+	}
+	p.decay(now)
+	return int(math.Ceil(p.dhw))
+}
+
+// limit returns the retention limit at time now.
+func (p *Of[T]) limit(now int64) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.decay(now)
+	return int(math.Ceil(p.dhw))
+}
+
+// Trim evicts from the pool the objects that have been idle for
+// longer than the IdleTTL and, with adaptive sizing, the (oldest)
+// objects in excess of the current demand mark (see Options). It is
+// called periodically by the janitor, if one is configured, but it
+// can also be called directly. Objects are examined by cycling them
+// through the pool, so concurrent calls to Get may miss some of them
+// while Trim runs. If neither IdleTTL nor adaptive sizing are
+// enabled, Trim does nothing.
+func (p *Of[T]) Trim() {
+	if p.ttl == 0 && p.hl == 0 {
+		return
+	}
+	now := p.now()
+	n := len(p.queue)
+	excess := 0
+	if p.hl != 0 {
+		excess = n - p.limit(now)
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case e := <-p.queue:
+			if excess > 0 || p.ttl != 0 && now-e.t > int64(p.ttl) {
+				p.st.evictions.Add(1)
+				excess--
+				continue
+			}
+			select {
+			case p.queue <- e:
+			default:
+				p.st.evictions.Add(1)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// janitor calls Trim every d, until ctx is canceled.
+func (p *Of[T]) janitor(ctx context.Context, d time.Duration) error {
+	tk := time.NewTicker(d)
+	defer tk.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tk.C:
+			p.Trim()
+		}
+	}
+}
+
+// closedChan is returned by WaitChan for pools without a janitor.
+var closedChan = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// Kill stops the pool's janitor, if one is running.
+func (p *Of[T]) Kill() task.Task {
+	if p.t != nil {
+		p.t.Kill()
+	}
+	return p
+}
+
+// Wait waits for the pool's janitor to exit. It returns immediately
+// if there is no janitor.
+func (p *Of[T]) Wait() error {
+	if p.t == nil {
+		return nil
+	}
+	return p.t.Wait()
+}
+
+// WaitChan returns a channel that is closed when the pool's janitor
+// exits (or a closed channel, if there is no janitor).
+func (p *Of[T]) WaitChan() <-chan struct{} {
+	if p.t == nil {
+		return closedChan
+	}
+	return p.t.WaitChan()
+}
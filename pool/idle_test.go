@@ -0,0 +1,116 @@
+package pool_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/npat-efault/gohacks/pool"
+)
+
+func TestIdleTTL(t *testing.T) {
+	p := pool.NewOfOpts(10, func() *S { return &S{id: -1} },
+		&pool.Options[*S]{IdleTTL: 50 * time.Millisecond})
+	p.Put(&S{id: 1})
+	time.Sleep(100 * time.Millisecond)
+	p.Put(&S{id: 2})
+	if s := p.Get(); s.id != 2 {
+		t.Fatalf("Item id %d != 2", s.id)
+	}
+	if st := p.Stats(); st.Evictions != 1 || st.Hits != 1 {
+		t.Fatalf("Stats %+v", st)
+	}
+
+	p.Put(&S{id: 3})
+	p.Put(&S{id: 4})
+	time.Sleep(100 * time.Millisecond)
+	p.Put(&S{id: 5})
+	p.Trim()
+	if st := p.Stats(); st.Evictions != 3 || st.Size != 1 {
+		t.Fatalf("Stats %+v", st)
+	}
+	if s := p.Get(); s.id != 5 {
+		t.Fatalf("Item id %d != 5", s.id)
+	}
+}
+
+func TestAdaptive(t *testing.T) {
+	p := pool.NewOfOpts(100, func() *S { return &S{} },
+		&pool.Options[*S]{HalfLife: 50 * time.Millisecond})
+	// Spike: 20 objects in use.
+	ss := make([]*S, 20)
+	for i := range ss {
+		ss[i] = p.Get()
+	}
+	for _, s := range ss {
+		p.Put(s)
+	}
+	if st := p.Stats(); st.Size != 20 {
+		t.Fatalf("Stats %+v", st)
+	}
+	// After 4 half-lives the demand mark is down to 20/16.
+	time.Sleep(200 * time.Millisecond)
+	p.Trim()
+	if st := p.Stats(); st.Size > 2 || st.Evictions < 18 {
+		t.Fatalf("Stats %+v", st)
+	}
+	// Steady demand of 1 object keeps one retained.
+	for i := 0; i < 10; i++ {
+		p.Put(p.Get())
+	}
+	if st := p.Stats(); st.Size < 1 {
+		t.Fatalf("Stats %+v", st)
+	}
+	// Puts beyond the demand mark are dropped.
+	p.Empty()
+	st0 := p.Stats()
+	for i := 0; i < 10; i++ {
+		p.Put(&S{})
+	}
+	if st := p.Stats(); st.Size > 2 || st.Drops-st0.Drops < 8 {
+		t.Fatalf("Stats %+v", st)
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	p := pool.NewOpts(10, nil, &pool.Options[interface{}]{
+		IdleTTL: 20 * time.Millisecond,
+		Janitor: 10 * time.Millisecond,
+	})
+	for i := 0; i < 5; i++ {
+		p.Put(&S{id: i})
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Stats().Size != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Objects not evicted: %+v", p.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	p.Kill()
+	select {
+	case <-p.WaitChan():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Janitor not stopped")
+	}
+	if err := p.Wait(); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	// No janitor.
+	q := pool.NewOf[int](1, nil)
+	q.Kill()
+	if err := q.Wait(); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+}
+
+func TestNewOptsNil(t *testing.T) {
+	p := pool.NewOpts(3, nil, &pool.Options[interface{}]{
+		Validate: func(i interface{}) bool { return i.(int) > 0 },
+	})
+	p.Put(nil)
+	p.Put(0)
+	p.Put(1)
+	if i := p.Get(); i != 1 {
+		t.Fatalf("Get %v != 1", i)
+	}
+}
@@ -22,15 +22,237 @@
 // one of the objects stored in it or (if the pool is empty) a new one
 // allocated using the supplied function.
 //
+// Type Of is a generic pool, for objects of a specific type, that
+// avoids the type assertions:
+//
+//     p = pool.NewOfOpts(1024, func() *Header { return &Header{} },
+//             &pool.Options[*Header]{Reset: (*Header).Clear})
+//     o := p.Get()
+//     ...
+//     p.Put(o)
+//
+// It can optionally reset objects as they are recycled, and validate
+// them before handing them out. Pool and ByteSlicePool are thin
+// wrappers over it.
+//
+// Sharded is a pool split in shards (local caches) to reduce lock
+// contention between goroutines using it in parallel.
+//
+// SizedByteSlicePool is a pool for byte-slices of varying sizes
+// (buffers), organized in power-of-2 size classes.
+//
+// Pools created with options (see Options) can also release objects
+// that stay idle for too long, and adapt the number of objects they
+// retain to recent demand.
+//
+// All pools keep statistics (see Stats) that can be exported with
+// expvar (see PublishExpvar) or in the Prometheus text format (see
+// WritePrometheus).
+//
 // It is safe to use the same pool concurrently from multiple
 // goroutines.
 //
 package pool
 
-// Pool is an object recycling pool.
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/npat-efault/gohacks/task"
+)
+
+// Options are optional pool parameters that can be passed to
+// NewOfOpts.
+type Options[T any] struct {
+	// Reset, if not nil, is called for every object recycled to
+	// the pool (by Of.Put), before it is stored. It should clear
+	// the object's state, so that it is not observed by the next
+	// user.
+	Reset func(T)
+	// Validate, if not nil, is called for every object retrieved
+	// from the pool (by Of.Get). If it returns false, the object
+	// is dropped, and another one is retrieved or allocated.
+	Validate func(T) bool
+	// IdleTTL, if not zero, enables idle-time eviction: Objects
+	// that have stayed in the pool for longer than IdleTTL are
+	// released (evicted). Eviction is lazy: idle objects are
+	// evicted when they are encountered by Of.Get, or by Of.Trim
+	// (which the janitor calls periodically, see below).
+	IdleTTL time.Duration
+	// HalfLife, if not zero, enables adaptive sizing: The pool
+	// tracks the number of objects in use (handed out by Of.Get
+	// and not yet recycled by Of.Put), and keeps an exponentially
+	// decaying high-water mark of it, which halves every HalfLife
+	// (unless demand picks up). Of.Put drops objects, instead of
+	// storing them, when the pool holds as many objects as this
+	// mark. Excess objects are also evicted by Of.Trim. This way,
+	// the number of retained objects follows recent demand, up to
+	// the pool capacity.
+	HalfLife time.Duration
+	// Janitor, if not zero, starts a background task that calls
+	// Of.Trim every Janitor interval. The task is stopped by
+	// Of.Kill.
+	Janitor time.Duration
+}
+
+// entry is a pool queue entry.
+type entry[T any] struct {
+	o T
+	t int64 /* time stored (see Of.now), if tracked */
+}
+
+// Of is an object recycling pool for objects of type T.
+//
+// Of implements task.Task: If a janitor is configured (see Options),
+// Kill stops it and Wait waits for it to exit. Otherwise Kill does
+// nothing and Wait returns immediately. The pool remains usable after
+// Kill.
+type Of[T any] struct {
+	queue    chan entry[T]
+	alloc    func() T
+	reset    func(T)
+	validate func(T) bool
+	ttl      time.Duration
+	hl       time.Duration
+	epoch    time.Time    /* reference for entry times */
+	t        *task.Single /* the janitor, or nil */
+	st       counters
+
+	mu    sync.Mutex /* protects the adaptive sizing state below */
+	inUse int        /* # of objects in use */
+	dhw   float64    /* decaying high-water mark of inUse */
+	dhwT  int64      /* time dhw was last decayed */
+}
+
+// NewOf creates and returns an object-recycling pool for objects of
+// type T with a capacity of "n" objects. Function "alloc" will be
+// called when an object is requested (by Of.Get) and the pool is
+// empty. It is ok to pass nil for alloc; in this case, if the pool is
+// empty, Of.Get will return the zero value of T.
+func NewOf[T any](n int, alloc func() T) *Of[T] {
+	return NewOfOpts(n, alloc, nil)
+}
+
+// NewOfOpts is similar to NewOf, but also accepts optional pool
+// parameters (opts may be nil). It panics if opts are invalid
+// (negative durations).
+func NewOfOpts[T any](n int, alloc func() T, opts *Options[T]) *Of[T] {
+	p := &Of[T]{}
+	p.alloc = alloc
+	p.queue = make(chan entry[T], n)
+	if opts != nil {
+		if opts.IdleTTL < 0 || opts.HalfLife < 0 || opts.Janitor < 0 {
+			panic("Invalid pool options")
+		}
+		p.reset = opts.Reset
+		p.validate = opts.Validate
+		p.ttl = opts.IdleTTL
+		p.hl = opts.HalfLife
+		p.epoch = time.Now()
+		if opts.Janitor > 0 {
+			p.t = task.Go(func(ctx context.Context) error {
+				return p.janitor(ctx, opts.Janitor)
+			})
+		}
+	}
+	return p
+}
+
+// now returns the time elapsed since the pool's epoch, in
+// nanoseconds. Being based on the monotonic clock, it is unaffected
+// by wall-clock changes.
+func (p *Of[T]) now() int64 {
+	return int64(time.Since(p.epoch))
+}
+
+// Put recycles (stores, returns) an object to the pool. The object is
+// first reset (if a Reset function was given when the pool was
+// created). If the pool is filled to capacity, or, with adaptive
+// sizing, to the current demand mark (see Options), the object is
+// dropped.
+func (p *Of[T]) Put(o T) {
+	p.st.puts.Add(1)
+	p.st.release()
+	if p.reset != nil {
+		p.reset(o)
+	}
+	e := entry[T]{o: o}
+	if p.ttl != 0 || p.hl != 0 {
+		e.t = p.now()
+	}
+	if p.hl != 0 && len(p.queue) >= p.returned(e.t) {
+		p.st.drops.Add(1)
+		return
+	}
+	select {
+	case p.queue <- e:
+		p.st.mark(len(p.queue))
+	default:
+		p.st.drops.Add(1)
+	}
+}
+
+// Get retrieves and returns an object from the pool. Objects rejected
+// by the Validate function (if one was given when the pool was
+// created), and idle objects (see Options), are dropped. If the pool
+// is empty, a new object is allocated and returned---provided that an
+// "alloc" function was given when the pool was created (see
+// NewOf). If no "alloc" function was given, and the pool is empty,
+// Of.Get returns the zero value of T.
+func (p *Of[T]) Get() T {
+	p.st.gets.Add(1)
+	p.st.inUse.Add(1)
+	var now int64
+	if p.ttl != 0 || p.hl != 0 {
+		now = p.now()
+	}
+	if p.hl != 0 {
+		p.taken(now)
+	}
+	for {
+		select {
+		case e := <-p.queue:
+			if p.ttl != 0 && now-e.t > int64(p.ttl) {
+				p.st.evictions.Add(1)
+				continue
+			}
+			if p.validate == nil || p.validate(e.o) {
+				p.st.hits.Add(1)
+				return e.o
+			}
+			p.st.rejects.Add(1)
+		default:
+			p.st.misses.Add(1)
+			var o T
+			if p.alloc != nil {
+				o = p.alloc()
+			}
+			return o
+		}
+	}
+}
+
+// Empty removes all objects from the pool.
+func (p *Of[T]) Empty() {
+	for {
+		select {
+		case <-p.queue:
+		default:
+			return
+		}
+	}
+}
+
+// Stats returns a snapshot of the pool's statistics.
+func (p *Of[T]) Stats() Stats {
+	return p.st.snapshot(len(p.queue))
+}
+
+// Pool is an object recycling pool. It is a wrapper over Of, for
+// objects of any type.
 type Pool struct {
-	queue chan interface{}
-	alloc func() interface{}
+	of *Of[interface{}]
 }
 
 // New creates and returns an object-recycling pool with a capacity of
@@ -39,19 +261,28 @@ type Pool struct {
 // for alloc; in this case, if the pool is empty, Pool.Get will return
 // nil.
 func New(n int, alloc func() interface{}) *Pool {
-	p := &Pool{}
-	p.alloc = alloc
-	p.queue = make(chan interface{}, n)
-	return p
+	return NewOpts(n, alloc, nil)
+}
+
+// NewOpts is similar to New, but also accepts optional pool
+// parameters (opts may be nil). See NewOfOpts.
+func NewOpts(n int, alloc func() interface{},
+	opts *Options[interface{}]) *Pool {
+	o := Options[interface{}]{}
+	if opts != nil {
+		o = *opts
+	}
+	validate := o.Validate
+	o.Validate = func(i interface{}) bool {
+		return i != nil && (validate == nil || validate(i))
+	}
+	return &Pool{of: NewOfOpts(n, alloc, &o)}
 }
 
 // Put recycles (stores, returns) an object to the pool. If the pool
 // is filled to capacity, the oject is dropped.
 func (p Pool) Put(i interface{}) {
-	select {
-	case p.queue <- i:
-	default:
-	}
+	p.of.Put(i)
 }
 
 // Get retrieves and returns an object from the pool. If the pool is
@@ -60,73 +291,75 @@ func (p Pool) Put(i interface{}) {
 // Pool.New). If no "alloc" function was given, and the pool is empty,
 // Pool.Get returns nil.
 func (p Pool) Get() interface{} {
-	var i interface{}
-	select {
-	case i = <-p.queue:
-	default:
-	}
-	if i == nil && p.alloc != nil {
-		i = p.alloc()
-	}
-	return i
+	return p.of.Get()
 }
 
 // Empty removes all objects from the pool.
 func (p Pool) Empty() {
-	for {
-		select {
-		case <-p.queue:
-		default:
-			return
-		}
-	}
+	p.of.Empty()
+}
+
+// Stats returns a snapshot of the pool's statistics.
+func (p Pool) Stats() Stats {
+	return p.of.Stats()
 }
 
-// ByteSlicePool is a specialized pool for byte-slices.
+// Trim evicts idle and excess objects from the pool. See Of.Trim.
+func (p Pool) Trim() {
+	p.of.Trim()
+}
+
+// Kill stops the pool's janitor, if one is running. See Of.Kill.
+func (p Pool) Kill() task.Task {
+	p.of.Kill()
+	return p
+}
+
+// Wait waits for the pool's janitor to exit. See Of.Wait.
+func (p Pool) Wait() error {
+	return p.of.Wait()
+}
+
+// WaitChan returns a channel that is closed when the pool's janitor
+// exits. See Of.WaitChan.
+func (p Pool) WaitChan() <-chan struct{} {
+	return p.of.WaitChan()
+}
+
+// ByteSlicePool is a specialized pool for byte-slices. It is a
+// wrapper over Of. It hands out the slices recycled to it regardless
+// of their size; for buffers of varying sizes see
+// SizedByteSlicePool.
 type ByteSlicePool struct {
-	queue chan []byte
-	alloc func() []byte
+	of *Of[[]byte]
 }
 
 // NewByteSlice creates and returns a recycling pool specialized for
 // byte-slices. See function New for more.
 func NewByteSlice(n int, alloc func() []byte) *ByteSlicePool {
-	p := &ByteSlicePool{}
-	p.alloc = alloc
-	p.queue = make(chan []byte, n)
-	return p
+	return &ByteSlicePool{of: NewOfOpts(n, alloc, &Options[[]byte]{
+		Validate: func(s []byte) bool { return s != nil },
+	})}
 }
 
 // Put recycles (stores, returns) a byte-slice to the pool. If the pool
 // is filled to capacity, the oject is dropped.
 func (p ByteSlicePool) Put(s []byte) {
-	select {
-	case p.queue <- s:
-	default:
-	}
+	p.of.Put(s)
 }
 
 // Get retrieves and returns a byte-slice from the pool. See Pool.Get
 // for more.
 func (p ByteSlicePool) Get() []byte {
-	var s []byte
-	select {
-	case s = <-p.queue:
-	default:
-	}
-	if s == nil && p.alloc != nil {
-		s = p.alloc()
-	}
-	return s
+	return p.of.Get()
 }
 
 // Empty removes all objects from the pool.
 func (p ByteSlicePool) Empty() {
-	for {
-		select {
-		case <-p.queue:
-		default:
-			return
-		}
-	}
+	p.of.Empty()
+}
+
+// Stats returns a snapshot of the pool's statistics.
+func (p ByteSlicePool) Stats() Stats {
+	return p.of.Stats()
 }
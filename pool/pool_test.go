@@ -118,8 +118,50 @@ func TestBSPoolEmpty(t *testing.T) {
 	}
 }
 
+func TestOf(t *testing.T) {
+	nalloc := 0
+	p := pool.NewOfOpts(10, func() *S { nalloc++; return &S{id: -1} },
+		&pool.Options[*S]{
+			Reset:    func(s *S) { s.buf = [64]byte{} },
+			Validate: func(s *S) bool { return s.id%3 != 0 },
+		})
+	for i := 0; i < 12; i++ {
+		s := &S{id: i}
+		s.buf[0] = 'x'
+		p.Put(s)
+	}
+	for i := 0; i < 10; i++ {
+		if i%3 == 0 {
+			continue
+		}
+		s := p.Get()
+		if s.id != i {
+			t.Fatalf("Item id %d != %d", s.id, i)
+		}
+		if s.buf[0] != 0 {
+			t.Fatalf("Item %d not reset", i)
+		}
+	}
+	if nalloc != 0 {
+		t.Fatalf("Allocated %d items", nalloc)
+	}
+	if s := p.Get(); s == nil || s.id != -1 || nalloc != 1 {
+		t.Fatal("No new allocation")
+	}
+}
+
+func TestOfNil(t *testing.T) {
+	p := pool.NewOf[[]int](2, nil)
+	p.Put([]int{1})
+	p.Empty()
+	if s := p.Get(); s != nil {
+		t.Fatal("Pool not empty")
+	}
+}
+
 // See allocations due to conversions from []byte to
-// interface{}. Avoided by specialized ByteSlice pool.
+// interface{}. Avoided by specialized ByteSlice pool, and by the
+// generic pool.
 //
 // run with:
 //   go test -v -benchmem -bench=.
@@ -143,6 +185,15 @@ func BenchmarkAllocByteSlicePool(b *testing.B) {
 	}
 }
 
+func BenchmarkAllocOf(b *testing.B) {
+	s := make([]byte, 10)
+	p := pool.NewOf[[]byte](1, nil)
+	for i := 0; i < b.N; i++ {
+		p.Put(s)
+		s = p.Get()
+	}
+}
+
 func BenchmarkAllocPool(b *testing.B) {
 	s := make([]byte, 10)
 	p := pool.New(1, nil)
@@ -0,0 +1,205 @@
+package pool
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// cacheLine is the assumed size of a CPU cache line, used to pad the
+// shards so that they do not share cache lines.
+const cacheLine = 64
+
+// shard is a local cache of a Sharded pool.
+type shard[T any] struct {
+	mu    sync.Mutex
+	items []T /* stack of objects, cap is the shard capacity */
+	_     [cacheLine]byte
+}
+
+// push stores o in the shard. Returns false if the shard is full.
+// Must be called with s.mu held.
+func (s *shard[T]) push(o T) bool {
+	if len(s.items) == cap(s.items) {
+		return false
+	}
+	s.items = append(s.items, o)
+	return true
+}
+
+// pop removes and returns the most recently stored object from the
+// shard. Returns ok == false if the shard is empty. Must be called
+// with s.mu held.
+func (s *shard[T]) pop() (o T, ok bool) {
+	n := len(s.items)
+	if n == 0 {
+		return o, false
+	}
+	o = s.items[n-1]
+	var zero T
+	s.items[n-1] = zero
+	s.items = s.items[:n-1]
+	return o, true
+}
+
+// Sharded is an object recycling pool for objects of type T, that is
+// split in a number of shards (local caches), each protected by its
+// own lock, in order to reduce contention between goroutines using
+// the pool in parallel. Every Get and Put starts from a randomly
+// selected shard (since goroutines cannot be bound to processors, a
+// per-goroutine random choice is used instead of sync.Pool's per-P
+// caches). If the shard is empty (on Get), objects are stolen from
+// the other shards; if it is full (on Put), the object is stored to
+// another shard. Stealing, and storing to other shards, only use
+// shards that are not locked at the time, so Get may allocate (and
+// Put may drop) even if other shards have objects (room).
+//
+// Unlike sync.Pool, the total number of objects retained by a
+// Sharded pool is bounded by its capacity, and objects are never
+// cleared by the garbage collector.
+//
+// Sharded pools do not support idle-time eviction and adaptive sizing
+// (see Options).
+type Sharded[T any] struct {
+	shards   []shard[T]
+	mask     uint32
+	alloc    func() T
+	reset    func(T)
+	validate func(T) bool
+	size     atomic.Int64 /* # of objects in the pool */
+	st       counters
+}
+
+// NewSharded creates and returns a sharded object-recycling pool for
+// objects of type T with a capacity of "n" objects, split in a number
+// of shards equal to runtime.GOMAXPROCS(0), rounded up to a power of
+// 2. Function "alloc" is used as with NewOf.
+func NewSharded[T any](n int, alloc func() T) *Sharded[T] {
+	return NewShardedOpts(n, 0, alloc, nil)
+}
+
+// NewShardedOpts is similar to NewSharded, but the number of shards
+// is given by "shards" (rounded up to a power of 2; if zero, the
+// default is used), and it also accepts optional pool parameters
+// (opts may be nil). Only the Reset and Validate options are
+// supported. It panics if n or shards are negative, or if opts are
+// invalid.
+func NewShardedOpts[T any](n, shards int, alloc func() T,
+	opts *Options[T]) *Sharded[T] {
+	if n < 0 || shards < 0 {
+		panic("Invalid pool size")
+	}
+	if shards == 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	k := 1
+	for k < shards {
+		k <<= 1
+	}
+	p := &Sharded[T]{alloc: alloc, mask: uint32(k - 1)}
+	if opts != nil {
+		if opts.IdleTTL != 0 || opts.HalfLife != 0 || opts.Janitor != 0 {
+			panic("Invalid pool options")
+		}
+		p.reset = opts.Reset
+		p.validate = opts.Validate
+	}
+	// Split the capacity so that the shard capacities add up to
+	// exactly n.
+	p.shards = make([]shard[T], k)
+	for i := range p.shards {
+		c := n / k
+		if i < n%k {
+			c++
+		}
+		p.shards[i].items = make([]T, 0, c)
+	}
+	return p
+}
+
+// Put recycles (stores, returns) an object to the pool. The object is
+// first reset (if a Reset function was given when the pool was
+// created). If the selected shard is full, the object is stored to
+// another one. If no shard with room can be found, the object is
+// dropped.
+func (p *Sharded[T]) Put(o T) {
+	p.st.puts.Add(1)
+	p.st.release()
+	if p.reset != nil {
+		p.reset(o)
+	}
+	i := rand.Uint32()
+	s := &p.shards[i&p.mask]
+	s.mu.Lock()
+	ok := s.push(o)
+	s.mu.Unlock()
+	for j := uint32(1); !ok && j <= p.mask; j++ {
+		s = &p.shards[(i+j)&p.mask]
+		if s.mu.TryLock() {
+			ok = s.push(o)
+			s.mu.Unlock()
+		}
+	}
+	if !ok {
+		p.st.drops.Add(1)
+		return
+	}
+	p.st.mark(int(p.size.Add(1)))
+}
+
+// Get retrieves and returns an object from the pool. If the selected
+// shard is empty, an object is stolen from another one. Objects
+// rejected by the Validate function (if one was given when the pool
+// was created) are dropped. If no object can be found, a new one is
+// allocated (see NewOf).
+func (p *Sharded[T]) Get() T {
+	p.st.gets.Add(1)
+	p.st.inUse.Add(1)
+	i := rand.Uint32()
+	for {
+		s := &p.shards[i&p.mask]
+		s.mu.Lock()
+		o, ok := s.pop()
+		s.mu.Unlock()
+		for j := uint32(1); !ok && j <= p.mask; j++ {
+			s = &p.shards[(i+j)&p.mask]
+			if s.mu.TryLock() {
+				o, ok = s.pop()
+				s.mu.Unlock()
+			}
+		}
+		if !ok {
+			break
+		}
+		p.size.Add(-1)
+		if p.validate == nil || p.validate(o) {
+			p.st.hits.Add(1)
+			return o
+		}
+		p.st.rejects.Add(1)
+	}
+	p.st.misses.Add(1)
+	var o T
+	if p.alloc != nil {
+		o = p.alloc()
+	}
+	return o
+}
+
+// Empty removes all objects from the pool.
+func (p *Sharded[T]) Empty() {
+	for i := range p.shards {
+		s := &p.shards[i]
+		s.mu.Lock()
+		for _, ok := s.pop(); ok; _, ok = s.pop() {
+			p.size.Add(-1)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Stats returns a snapshot of the pool's statistics.
+func (p *Sharded[T]) Stats() Stats {
+	return p.st.snapshot(int(p.size.Load()))
+}
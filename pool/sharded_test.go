@@ -0,0 +1,122 @@
+package pool_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/npat-efault/gohacks/pool"
+)
+
+func TestSharded(t *testing.T) {
+	for _, shards := range []int{0, 1, 3, 8} {
+		nalloc := 0
+		p := pool.NewShardedOpts(10, shards,
+			func() *S { nalloc++; return &S{id: -1} },
+			&pool.Options[*S]{
+				Reset:    func(s *S) { s.buf = [64]byte{} },
+				Validate: func(s *S) bool { return s.id != 0 },
+			})
+		for i := 0; i < 12; i++ {
+			s := &S{id: i}
+			s.buf[0] = 'x'
+			p.Put(s)
+		}
+		st := p.Stats()
+		if st.Size != 10 || st.Drops != 2 || st.HighWater != 10 {
+			t.Fatalf("shards %d: Stats %+v", shards, st)
+		}
+		seen := map[int]bool{}
+		for i := 0; i < 9; i++ {
+			s := p.Get()
+			if s.id <= 0 || s.id > 11 || seen[s.id] {
+				t.Fatalf("shards %d: Bad item id %d", shards, s.id)
+			}
+			if s.buf[0] != 0 {
+				t.Fatalf("shards %d: Item %d not reset",
+					shards, s.id)
+			}
+			seen[s.id] = true
+		}
+		if nalloc != 0 {
+			t.Fatalf("shards %d: Allocated %d items", shards, nalloc)
+		}
+		if s := p.Get(); s.id != -1 || nalloc != 1 {
+			t.Fatalf("shards %d: No new allocation", shards)
+		}
+		p.Put(&S{})
+		p.Empty()
+		if st := p.Stats(); st.Size != 0 {
+			t.Fatalf("shards %d: Pool not empty: %+v", shards, st)
+		}
+	}
+}
+
+func TestShardedConcurrent(t *testing.T) {
+	const n = 16
+	p := pool.NewShardedOpts(n, 4, func() *S { return &S{} }, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ss := make([]*S, 0, 8)
+			for j := 0; j < 1000; j++ {
+				ss = append(ss, p.Get())
+				if len(ss) == cap(ss) {
+					for _, s := range ss {
+						p.Put(s)
+					}
+					ss = ss[:0]
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	st := p.Stats()
+	if st.HighWater > n || st.Size > n ||
+		st.Puts-st.Drops != st.Hits+uint64(st.Size) || st.InUse != 0 {
+		t.Fatalf("Inconsistent stats: %+v", st)
+	}
+}
+
+// Compare the sharded pool against the channel-based pools, and
+// sync.Pool, under parallel load.
+//
+// run with:
+//   go test -run=XXX -benchmem -bench=Parallel -cpu=1,4,16
+
+func BenchmarkParallelSharded(b *testing.B) {
+	p := pool.NewSharded(1024, func() *S { return &S{} })
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.Put(p.Get())
+		}
+	})
+}
+
+func BenchmarkParallelOf(b *testing.B) {
+	p := pool.NewOf(1024, func() *S { return &S{} })
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.Put(p.Get())
+		}
+	})
+}
+
+func BenchmarkParallelPool(b *testing.B) {
+	p := pool.New(1024, func() interface{} { return &S{} })
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.Put(p.Get())
+		}
+	})
+}
+
+func BenchmarkParallelSyncPool(b *testing.B) {
+	p := sync.Pool{New: func() interface{} { return &S{} }}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.Put(p.Get())
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package pool
+
+import "math/bits"
+
+// SizedByteSlicePool is a recycling pool for byte-slices of varying
+// sizes (buffers). It is organized in size classes: Each class holds
+// slices of a specific capacity, which is a power of 2, from a
+// minimum to a maximum size. Slices are retrieved from the class that
+// can satisfy the requested size (see SizedByteSlicePool.Get), and
+// recycled to the class that corresponds to their capacity (see
+// SizedByteSlicePool.Put). Each class has its own retention limit
+// (capacity).
+//
+// It is safe to use the same pool concurrently from multiple
+// goroutines.
+type SizedByteSlicePool struct {
+	min     int /* size of the smallest class */
+	max     int /* size of the largest class */
+	minBits int /* log2(min) */
+	classes []*Of[[]byte]
+}
+
+// NewSizedByteSlice creates and returns a recycling pool for
+// byte-slices with sizes from minSz to maxSz, with a retention limit
+// of n slices for each size class. Both minSz and maxSz must be powers
+// of 2, and minSz <= maxSz. Otherwise, NewSizedByteSlice panics.
+func NewSizedByteSlice(minSz, maxSz, n int) *SizedByteSlicePool {
+	return NewSizedByteSliceFunc(minSz, maxSz,
+		func(int) int { return n })
+}
+
+// NewSizedByteSliceFunc is similar to NewSizedByteSlice, but the
+// retention limit for each size class is determined by calling
+// function retain, with the class size as argument.
+func NewSizedByteSliceFunc(minSz, maxSz int,
+	retain func(sz int) int) *SizedByteSlicePool {
+	if minSz <= 0 || minSz&(minSz-1) != 0 ||
+		maxSz < minSz || maxSz&(maxSz-1) != 0 {
+		panic("Invalid pool size")
+	}
+	p := &SizedByteSlicePool{
+		min:     minSz,
+		max:     maxSz,
+		minBits: bits.Len(uint(minSz)) - 1,
+	}
+	for sz := minSz; sz <= maxSz; sz <<= 1 {
+		sz := sz
+		p.classes = append(p.classes, NewOf(retain(sz),
+			func() []byte { return make([]byte, sz) }))
+	}
+	return p
+}
+
+// Get retrieves and returns a byte-slice of length n, and capacity of
+// at least n, from the pool. The slice is retrieved from the smallest
+// size class that can satisfy the request, or it is allocated if the
+// class is empty. If n is larger than the maximum size of the pool,
+// a new slice is allocated (of length and capacity n). The contents
+// of the returned slice are not cleared. Get panics if n < 0.
+func (p *SizedByteSlicePool) Get(n int) []byte {
+	if n < 0 {
+		panic("Invalid slice size")
+	}
+	if n > p.max {
+		return make([]byte, n)
+	}
+	i := 0
+	if n > p.min {
+		i = bits.Len(uint(n-1)) - p.minBits
+	}
+	return p.classes[i].Get()[:n]
+}
+
+// Put recycles (stores, returns) a byte-slice to the pool. The slice
+// is stored to the largest size class that is not larger than its
+// capacity (so slices not obtained by Get can also be recycled). If
+// the slice's capacity is smaller than the minimum, or larger than the
+// maximum size of the pool, or if the respective class is filled to
+// capacity, the slice is dropped.
+func (p *SizedByteSlicePool) Put(s []byte) {
+	c := cap(s)
+	if c < p.min || c > p.max {
+		return
+	}
+	i := bits.Len(uint(c)) - 1 - p.minBits
+	p.classes[i].Put(s[:p.min<<uint(i)])
+}
+
+// Empty removes all slices from the pool.
+func (p *SizedByteSlicePool) Empty() {
+	for _, c := range p.classes {
+		c.Empty()
+	}
+}
+
+// Stats returns a snapshot of the pool's statistics, summed over all
+// size classes (HighWater is the sum of the classes' high-water
+// marks). Gets and Puts of slices outside the pool's size range are
+// not counted.
+func (p *SizedByteSlicePool) Stats() Stats {
+	var st Stats
+	for _, c := range p.classes {
+		st.add(c.Stats())
+	}
+	return st
+}
@@ -0,0 +1,82 @@
+package pool_test
+
+import (
+	"testing"
+
+	"github.com/npat-efault/gohacks/pool"
+)
+
+func TestSizedGet(t *testing.T) {
+	p := pool.NewSizedByteSlice(512, 64*1024, 4)
+	for _, tc := range []struct{ n, c int }{
+		{0, 512}, {1, 512}, {512, 512}, {513, 1024}, {1024, 1024},
+		{1025, 2048}, {40000, 64 * 1024}, {64 * 1024, 64 * 1024},
+		{64*1024 + 1, 64*1024 + 1},
+	} {
+		b := p.Get(tc.n)
+		if len(b) != tc.n || cap(b) != tc.c {
+			t.Fatalf("Get(%d): len/cap %d/%d != %d/%d",
+				tc.n, len(b), cap(b), tc.n, tc.c)
+		}
+	}
+}
+
+func TestSizedPut(t *testing.T) {
+	p := pool.NewSizedByteSliceFunc(512, 4096,
+		func(sz int) int { return 4096 / sz })
+	put := func(c int, mark byte) {
+		b := make([]byte, 1, c)
+		b[0] = mark
+		p.Put(b)
+	}
+	put(256, 1)  // too small, dropped
+	put(8192, 2) // too large, dropped
+	put(3000, 3) // goes to class 2048
+	put(4096, 4)
+	put(4096, 5) // class full, dropped
+	if b := p.Get(1500); b[0] != 3 || cap(b) != 3000 {
+		t.Fatalf("Get(1500): mark/cap %d/%d != 3/3000", b[0], cap(b))
+	}
+	if b := p.Get(2049); b[0] != 4 {
+		t.Fatalf("Get(2049): mark %d != 4", b[0])
+	}
+	if b := p.Get(4000); b[0] != 0 {
+		t.Fatalf("Get(4000): mark %d != 0", b[0])
+	}
+	if b := p.Get(100); b[0] != 0 || cap(b) != 512 {
+		t.Fatalf("Get(100): mark/cap %d/%d != 0/512", b[0], cap(b))
+	}
+	for i := 0; i < 10; i++ {
+		put(512, byte(10+i))
+	}
+	if st := p.Stats(); st.Puts != 13 || st.Drops != 3 ||
+		st.Size != 8 || st.HighWater != 10 {
+		t.Fatalf("Stats %+v", st)
+	}
+	p.Empty()
+	if b := p.Get(512); b[0] != 0 {
+		t.Fatal("Pool not empty")
+	}
+}
+
+func TestSizedInvalid(t *testing.T) {
+	for _, sz := range [][2]int{{0, 512}, {500, 1024}, {1024, 512},
+		{512, 1000}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("No panic for sizes %v", sz)
+				}
+			}()
+			pool.NewSizedByteSlice(sz[0], sz[1], 1)
+		}()
+	}
+}
+
+func BenchmarkSizedByteSlicePool(b *testing.B) {
+	p := pool.NewSizedByteSlice(512, 64*1024, 16)
+	for i := 0; i < b.N; i++ {
+		s := p.Get(i % (64 * 1024))
+		p.Put(s)
+	}
+}
@@ -0,0 +1,131 @@
+package pool
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// Stats is a snapshot of pool statistics. Counters are cumulative,
+// since the creation of the pool. InUse is tracked for all pools,
+// regardless of adaptive sizing (see Options).
+type Stats struct {
+	Gets      uint64 `json:"gets"`       /* # of Get calls */
+	Hits      uint64 `json:"hits"`       /* # of Gets served from the pool */
+	Misses    uint64 `json:"misses"`     /* # of Gets not served from the pool */
+	Rejects   uint64 `json:"rejects"`    /* # of objects rejected by Validate */
+	Puts      uint64 `json:"puts"`       /* # of Put calls */
+	Drops     uint64 `json:"drops"`      /* # of Puts dropped, pool full */
+	Evictions uint64 `json:"evictions"`  /* # of idle or excess objects evicted */
+	Size      int    `json:"size"`       /* # of objects currently in the pool */
+	HighWater int    `json:"high_water"` /* max # of objects in the pool */
+	InUse     int    `json:"in_use"`     /* # of objects got and not yet put */
+}
+
+// counters are the pool statistics counters, updated atomically.
+type counters struct {
+	gets, hits, misses, rejects atomic.Uint64
+	puts, drops, evictions      atomic.Uint64
+	hw                          atomic.Int64 /* high-water mark */
+	inUse                       atomic.Int64 /* # of objects in use */
+}
+
+// release updates the in-use count when an object is put to the
+// pool. The count never drops below zero, so objects that were not
+// obtained by Get (e.g. put to fill the pool) are not counted.
+func (c *counters) release() {
+	for {
+		n := c.inUse.Load()
+		if n <= 0 || c.inUse.CompareAndSwap(n, n-1) {
+			return
+		}
+	}
+}
+
+// mark updates the high-water mark, given the current pool size n.
+func (c *counters) mark(n int) {
+	for {
+		hw := c.hw.Load()
+		if int64(n) <= hw || c.hw.CompareAndSwap(hw, int64(n)) {
+			return
+		}
+	}
+}
+
+// snapshot returns the counter values, for a pool of size n.
+func (c *counters) snapshot(n int) Stats {
+	return Stats{
+		Gets:      c.gets.Load(),
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Rejects:   c.rejects.Load(),
+		Puts:      c.puts.Load(),
+		Drops:     c.drops.Load(),
+		Evictions: c.evictions.Load(),
+		Size:      n,
+		HighWater: int(c.hw.Load()),
+		InUse:     int(c.inUse.Load()),
+	}
+}
+
+// add adds the statistics s to st.
+func (st *Stats) add(s Stats) {
+	st.Gets += s.Gets
+	st.Hits += s.Hits
+	st.Misses += s.Misses
+	st.Rejects += s.Rejects
+	st.Puts += s.Puts
+	st.Drops += s.Drops
+	st.Evictions += s.Evictions
+	st.Size += s.Size
+	st.HighWater += s.HighWater
+	st.InUse += s.InUse
+}
+
+// Statser is implemented by the pools that provide statistics.
+type Statser interface {
+	Stats() Stats
+}
+
+// PublishExpvar publishes the statistics of pool p as an expvar
+// variable with the given name. The statistics are exported as a JSON
+// object, and are read every time the variable is accessed. Like
+// expvar.Publish, it panics if name is already registered.
+func PublishExpvar(name string, p Statser) {
+	expvar.Publish(name, expvar.Func(func() any { return p.Stats() }))
+}
+
+// WritePrometheus writes statistics s to w, in the Prometheus text
+// exposition format. Metric names are prefixed with prefix (e.g. for
+// prefix "myapp_hdrpool", the number of gets is written as
+// "myapp_hdrpool_gets_total").
+func WritePrometheus(w io.Writer, prefix string, s Stats) error {
+	for _, m := range []struct {
+		name, typ, help string
+		v               uint64
+	}{
+		{"gets_total", "counter", "Get calls.", s.Gets},
+		{"hits_total", "counter", "Gets served from the pool.", s.Hits},
+		{"misses_total", "counter", "Gets not served from the pool.", s.Misses},
+		{"rejects_total", "counter", "Objects rejected by Validate.",
+			s.Rejects},
+		{"puts_total", "counter", "Put calls.", s.Puts},
+		{"drops_total", "counter", "Puts dropped, pool full.", s.Drops},
+		{"evictions_total", "counter", "Idle or excess objects evicted.",
+			s.Evictions},
+		{"size", "gauge", "Objects in the pool.", uint64(s.Size)},
+		{"high_water", "gauge", "Max objects in the pool.",
+			uint64(s.HighWater)},
+		{"in_use", "gauge", "Objects got and not yet put.",
+			uint64(s.InUse)},
+	} {
+		_, err := fmt.Fprintf(w, "# HELP %s_%s %s\n# TYPE %s_%s %s\n%s_%s %d\n",
+			prefix, m.name, m.help, prefix, m.name, m.typ,
+			prefix, m.name, m.v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package pool_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/npat-efault/gohacks/pool"
+)
+
+func TestStats(t *testing.T) {
+	p := pool.NewOfOpts(4, func() *S { return &S{} },
+		&pool.Options[*S]{
+			Validate: func(s *S) bool { return s.id >= 0 },
+		})
+	for i := 0; i < 6; i++ {
+		p.Put(&S{id: i - 1})
+	}
+	for i := 0; i < 5; i++ {
+		p.Get()
+	}
+	want := pool.Stats{Gets: 5, Hits: 3, Misses: 2, Rejects: 1,
+		Puts: 6, Drops: 2, Size: 0, HighWater: 4, InUse: 5}
+	if st := p.Stats(); st != want {
+		t.Fatalf("Stats %+v != %+v", st, want)
+	}
+}
+
+func TestStatsConcurrent(t *testing.T) {
+	p := pool.New(8, func() interface{} { return &S{} })
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				p.Put(p.Get())
+			}
+		}()
+	}
+	wg.Wait()
+	st := p.Stats()
+	if st.Gets != 4000 || st.Puts != 4000 ||
+		st.Hits+st.Misses != st.Gets ||
+		st.Puts-st.Drops != st.Hits+uint64(st.Size) ||
+		st.HighWater > 8 || st.HighWater < st.Size || st.InUse != 0 {
+		t.Fatalf("Inconsistent stats: %+v", st)
+	}
+}
+
+// nExpvar makes the names of the published expvar variables unique
+// across test runs in the same process (e.g. with -count).
+var nExpvar int
+
+func TestPublishExpvar(t *testing.T) {
+	p := pool.NewByteSlice(4, nil)
+	p.Put(make([]byte, 8))
+	p.Get()
+	nExpvar++
+	name := fmt.Sprintf("pool_test_bsp_%d", nExpvar)
+	pool.PublishExpvar(name, p)
+	var st pool.Stats
+	if err := json.Unmarshal(
+		[]byte(expvar.Get(name).String()), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st != p.Stats() || st.InUse != 1 {
+		t.Fatalf("Stats %+v != %+v", st, p.Stats())
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	var b strings.Builder
+	err := pool.WritePrometheus(&b, "hdr_pool",
+		pool.Stats{Gets: 10, Hits: 7, Size: 3, HighWater: 5, InUse: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := b.String()
+	for _, l := range []string{
+		"# TYPE hdr_pool_gets_total counter\n",
+		"\nhdr_pool_gets_total 10\n",
+		"\nhdr_pool_hits_total 7\n",
+		"\nhdr_pool_drops_total 0\n",
+		"# TYPE hdr_pool_size gauge\n",
+		"\nhdr_pool_high_water 5\n",
+		"# TYPE hdr_pool_in_use gauge\n",
+		"\nhdr_pool_in_use 4\n",
+	} {
+		if !strings.Contains(s, l) {
+			t.Fatalf("Missing %q in:\n%s", l, s)
+		}
+	}
+}
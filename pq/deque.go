@@ -0,0 +1,316 @@
+// Copyright (c) 2016, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+package pq
+
+import (
+	"cmp"
+	"math/bits"
+)
+
+// Deque is a double-ended priority queue, implemented as a min-max
+// heap. It gives access to both its "least" (front) and its
+// "greatest" (back) element, according to the comparison function
+// given when the deque was created. Peeking at either end takes O(1)
+// time, and popping from either end takes O(log n) time. Methods are
+// named after those of cirq.Queue, with the front and back of the
+// deque determined by priority, instead of insertion order. Elements
+// can be updated and removed through handles returned when they are
+// pushed.
+//
+// In a min-max heap, nodes at even levels (the root is at level 0)
+// are less than or equal to all their descendants, while nodes at odd
+// levels are greater than or equal to all their descendants.
+type Deque[T any] struct {
+	less func(a, b T) bool
+	b    []*Handle[T] /* heap storage */
+}
+
+// NewDeque creates and returns a new priority deque ordered by the
+// comparison function less. The deque is initially allocated with
+// space for sz elements. It grows, as required, to accommodate more.
+func NewDeque[T any](sz int, less func(a, b T) bool) *Deque[T] {
+	if sz < 1 {
+		sz = 1
+	}
+	return &Deque[T]{less: less, b: make([]*Handle[T], 0, sz)}
+}
+
+// NewOrderedDeque creates and returns a new priority deque for ordered
+// element types. See NewDeque.
+func NewOrderedDeque[T cmp.Ordered](sz int) *Deque[T] {
+	return NewDeque(sz, cmp.Less[T])
+}
+
+// Len returns the number of elements in the deque.
+func (d *Deque[T]) Len() int {
+	return len(d.b)
+}
+
+// Empty tests if the deque is empty.
+func (d *Deque[T]) Empty() bool {
+	return len(d.b) == 0
+}
+
+// Cap returns the capacity of the deque (# of element slots currently
+// allocated).
+func (d *Deque[T]) Cap() int {
+	return cap(d.b)
+}
+
+// Push adds element v to the deque, and returns a handle for it.
+func (d *Deque[T]) Push(v T) *Handle[T] {
+	if len(d.b) == cap(d.b) {
+		d.resize(2 * cap(d.b))
+	}
+	hd := &Handle[T]{v: v, i: len(d.b)}
+	d.b = append(d.b, hd)
+	d.up(hd.i)
+	return hd
+}
+
+// back returns the index of the greatest element. The deque must not
+// be empty.
+func (d *Deque[T]) back() int {
+	switch len(d.b) {
+	case 1:
+		return 0
+	case 2:
+		return 1
+	}
+	if d.less(d.b[1].v, d.b[2].v) {
+		return 2
+	}
+	return 1
+}
+
+// PeekFront returns the least element of the deque, without removing
+// it. Returns ok == false if the deque is empty (unable to peek
+// element), ok == true otherwise.
+func (d *Deque[T]) PeekFront() (v T, ok bool) {
+	if len(d.b) == 0 {
+		return v, false
+	}
+	return d.b[0].v, true
+}
+
+// MustPeekFront returns the least element of the deque, without
+// removing it. Panics if the deque is empty.
+func (d *Deque[T]) MustPeekFront() (v T) {
+	if len(d.b) == 0 {
+		panic("MustPeekFront from empty deque")
+	}
+	return d.b[0].v
+}
+
+// PeekBack returns the greatest element of the deque, without
+// removing it. Returns ok == false if the deque is empty (unable to
+// peek element), ok == true otherwise.
+func (d *Deque[T]) PeekBack() (v T, ok bool) {
+	if len(d.b) == 0 {
+		return v, false
+	}
+	return d.b[d.back()].v, true
+}
+
+// MustPeekBack returns the greatest element of the deque, without
+// removing it. Panics if the deque is empty.
+func (d *Deque[T]) MustPeekBack() (v T) {
+	if len(d.b) == 0 {
+		panic("MustPeekBack from empty deque")
+	}
+	return d.b[d.back()].v
+}
+
+// PopFront removes the least element from the deque and returns
+// it. Returns ok == false if the deque was empty (unable to pop
+// element), ok == true otherwise.
+func (d *Deque[T]) PopFront() (v T, ok bool) {
+	if len(d.b) == 0 {
+		return v, false
+	}
+	return d.remove(0).v, true
+}
+
+// PopBack removes the greatest element from the deque and returns
+// it. Returns ok == false if the deque was empty (unable to pop
+// element), ok == true otherwise.
+func (d *Deque[T]) PopBack() (v T, ok bool) {
+	if len(d.b) == 0 {
+		return v, false
+	}
+	return d.remove(d.back()).v, true
+}
+
+// Remove removes the element identified by hd from the deque. Returns
+// ok == false if the element was not in the deque (e.g. it has
+// already been removed), ok == true otherwise.
+func (d *Deque[T]) Remove(hd *Handle[T]) (ok bool) {
+	if !d.has(hd) {
+		return false
+	}
+	d.remove(hd.i)
+	return true
+}
+
+// Update replaces the value of the element identified by hd with v,
+// and restores the deque ordering. Returns ok == false if the element
+// was not in the deque, ok == true otherwise.
+func (d *Deque[T]) Update(hd *Handle[T], v T) (ok bool) {
+	if !d.has(hd) {
+		return false
+	}
+	hd.v = v
+	d.fix(hd.i)
+	return true
+}
+
+// Fix restores the deque ordering after the priority of the element
+// identified by hd has changed in-place (see Heap.Fix). Returns ok ==
+// false if the element was not in the deque, ok == true otherwise.
+func (d *Deque[T]) Fix(hd *Handle[T]) (ok bool) {
+	if !d.has(hd) {
+		return false
+	}
+	d.fix(hd.i)
+	return true
+}
+
+// Clear removes all elements from the deque. It does not change its
+// capacity.
+func (d *Deque[T]) Clear() {
+	for _, hd := range d.b {
+		hd.i = -1
+	}
+	clear(d.b)
+	d.b = d.b[:0]
+}
+
+// Compact resizes the deque storage (without removing elements from
+// the deque) to the smallest possible size, but not smaller than sz.
+func (d *Deque[T]) Compact(sz int) {
+	if sz < len(d.b) {
+		sz = len(d.b)
+	}
+	if sz < 1 {
+		sz = 1
+	}
+	if sz != cap(d.b) {
+		d.resize(sz)
+	}
+}
+
+// has tests if hd identifies an element of d.
+func (d *Deque[T]) has(hd *Handle[T]) bool {
+	return hd != nil && hd.i >= 0 && hd.i < len(d.b) && d.b[hd.i] == hd
+}
+
+// remove removes the i'th element from the deque and returns its
+// handle.
+func (d *Deque[T]) remove(i int) *Handle[T] {
+	n := len(d.b) - 1
+	hd := d.b[i]
+	if i != n {
+		d.swap(i, n)
+	}
+	d.b[n] = nil
+	d.b = d.b[:n]
+	if i != n {
+		d.fix(i)
+	}
+	hd.i = -1
+	return hd
+}
+
+// resize re-allocates the deque storage with capacity sz. The caller
+// must make sure that sz >= d.Len().
+func (d *Deque[T]) resize(sz int) {
+	b := make([]*Handle[T], len(d.b), sz)
+	copy(b, d.b)
+	d.b = b
+}
+
+func (d *Deque[T]) swap(i, j int) {
+	d.b[i], d.b[j] = d.b[j], d.b[i]
+	d.b[i].i = i
+	d.b[j].i = j
+}
+
+// isMin tests if the i'th node is at a min (even) level.
+func isMin(i int) bool {
+	return bits.Len(uint(i+1))&1 == 1
+}
+
+// cmp compares the i'th and the j'th elements. For nodes at min levels
+// (mn == true) it returns less(b[i], b[j]). For nodes at max levels
+// it returns less(b[j], b[i]).
+func (d *Deque[T]) cmp(mn bool, i, j int) bool {
+	if mn {
+		return d.less(d.b[i].v, d.b[j].v)
+	}
+	return d.less(d.b[j].v, d.b[i].v)
+}
+
+// fix moves the i'th element up or down the deque, as required, to
+// restore the deque ordering.
+func (d *Deque[T]) fix(i int) {
+	hd := d.b[i]
+	d.down(i)
+	d.up(hd.i)
+}
+
+// up moves the i'th element up the deque.
+func (d *Deque[T]) up(i int) {
+	if i == 0 {
+		return
+	}
+	mn, p := isMin(i), (i-1)/2
+	if d.cmp(!mn, i, p) {
+		// Element belongs to the levels of the other kind.
+		d.swap(i, p)
+		i, mn = p, !mn
+	}
+	// Move up through grandparents of the same kind.
+	for i > 2 {
+		g := ((i-1)/2 - 1) / 2
+		if !d.cmp(mn, i, g) {
+			break
+		}
+		d.swap(i, g)
+		i = g
+	}
+}
+
+// down moves the i'th element down the deque.
+func (d *Deque[T]) down(i int) {
+	mn, n := isMin(i), len(d.b)
+	for {
+		// Find the least (for min levels) or greatest (for
+		// max levels) among children and grandchildren.
+		c := 2*i + 1
+		if c >= n {
+			return
+		}
+		m := c
+		for _, j := range [...]int{c + 1, 2*c + 1, 2*c + 2,
+			2*c + 3, 2*c + 4} {
+			if j < n && d.cmp(mn, j, m) {
+				m = j
+			}
+		}
+		if !d.cmp(mn, m, i) {
+			return
+		}
+		d.swap(m, i)
+		if m <= c+1 {
+			// m is a child.
+			return
+		}
+		if p := (m - 1) / 2; d.cmp(mn, p, m) {
+			d.swap(m, p)
+		}
+		i = m
+	}
+}
@@ -0,0 +1,117 @@
+package pq_test
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/npat-efault/gohacks/pq"
+)
+
+// checkEnds checks the front and back of d against the model slice s.
+func checkEnds(t *testing.T, d *pq.Deque[int], s []int) {
+	t.Helper()
+	if d.Len() != len(s) {
+		t.Fatalf("Deque len %d != %d", d.Len(), len(s))
+	}
+	if len(s) == 0 {
+		return
+	}
+	if v := d.MustPeekFront(); v != slices.Min(s) {
+		t.Fatalf("Front %d != %d", v, slices.Min(s))
+	}
+	if v := d.MustPeekBack(); v != slices.Max(s) {
+		t.Fatalf("Back %d != %d", v, slices.Max(s))
+	}
+}
+
+func TestDequeRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	d := pq.NewOrderedDeque[int](1)
+	var s []int
+	hds := map[*pq.Handle[int]]bool{}
+	for i := 0; i < 5000; i++ {
+		switch op := r.Intn(10); {
+		case op < 4:
+			v := r.Intn(1000)
+			hds[d.Push(v)] = true
+			s = append(s, v)
+		case op < 5 && len(s) > 0:
+			v, _ := d.PopFront()
+			j := slices.Index(s, slices.Min(s))
+			if v != s[j] {
+				t.Fatalf("PopFront %d != %d", v, s[j])
+			}
+			s = slices.Delete(s, j, j+1)
+		case op < 6 && len(s) > 0:
+			v, _ := d.PopBack()
+			j := slices.Index(s, slices.Max(s))
+			if v != s[j] {
+				t.Fatalf("PopBack %d != %d", v, s[j])
+			}
+			s = slices.Delete(s, j, j+1)
+		default:
+			// Update or remove a random live element.
+			for hd := range hds {
+				j := slices.Index(s, hd.Value())
+				if d.Len() == 0 || !d.Fix(hd) {
+					delete(hds, hd)
+					continue
+				}
+				if op < 8 {
+					v := r.Intn(1000)
+					d.Update(hd, v)
+					s[j] = v
+				} else {
+					d.Remove(hd)
+					s = slices.Delete(s, j, j+1)
+				}
+				break
+			}
+		}
+		checkEnds(t, d, s)
+	}
+	slices.Sort(s)
+	for i := 0; !d.Empty(); i++ {
+		var v int
+		if i%2 == 0 {
+			v, _ = d.PopFront()
+			if v != s[0] {
+				t.Fatalf("PopFront %d != %d", v, s[0])
+			}
+			s = s[1:]
+		} else {
+			v, _ = d.PopBack()
+			if v != s[len(s)-1] {
+				t.Fatalf("PopBack %d != %d", v, s[len(s)-1])
+			}
+			s = s[:len(s)-1]
+		}
+	}
+}
+
+func TestDequeEmpty(t *testing.T) {
+	d := pq.NewDeque(0, func(a, b string) bool { return a < b })
+	if _, ok := d.PeekFront(); ok {
+		t.Fatal("PeekFront from empty deque")
+	}
+	if _, ok := d.PopBack(); ok {
+		t.Fatal("PopBack from empty deque")
+	}
+	hd := d.Push("a")
+	d.Push("b")
+	d.Clear()
+	if !d.Empty() || d.Remove(hd) {
+		t.Fatal("Deque not cleared")
+	}
+	d.Compact(0)
+	if d.Cap() != 1 {
+		t.Fatalf("Deque cap %d != 1", d.Cap())
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("No panic when peeking empty deque")
+		}
+	}()
+	d.MustPeekBack()
+}
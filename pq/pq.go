@@ -0,0 +1,293 @@
+// Copyright (c) 2016, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+// Package pq provides priority queues implemented as binary heaps. The
+// heaps are parametrized by the type of their elements and ordered by
+// a user-supplied comparison function, so no container/heap style
+// boilerplate is required. Elements can be updated and removed
+// through handles returned when they are pushed. Heap is a plain
+// priority queue, while Deque is a double-ended one (min-max heap),
+// giving access to both its least and its greatest element.
+//
+// Like the queues in package cirq, heaps allocate storage for a
+// user-specified number of elements, grow (by doubling) when
+// required, and shrink only when explicitly Compact'ed.
+//
+// Heap operations are *NOT* thread safe.
+package pq
+
+import "cmp"
+
+// Handle identifies an element in a heap. It is returned when the
+// element is pushed, and can be used to update or remove it.
+type Handle[T any] struct {
+	v T   /* element value */
+	i int /* index in heap slice, -1 if not in heap */
+}
+
+// Value returns the value of the element identified by the handle.
+func (hd *Handle[T]) Value() T {
+	return hd.v
+}
+
+// Heap is a binary heap (priority queue). The element at the top of
+// the heap (the one returned by Peek and Pop) is the "least" one,
+// according to the comparison function given when the heap was
+// created. That is, for a less function that returns a < b the heap is
+// a min-heap, while for one that returns a > b it is a max-heap.
+type Heap[T any] struct {
+	less func(a, b T) bool
+	k    int          /* max # of elements (top-K mode), or 0 */
+	sz   int          /* min allocated size */
+	b    []*Handle[T] /* heap storage */
+}
+
+// New creates and returns a new heap ordered by the comparison
+// function less. The heap is initially allocated with space for sz
+// elements. It grows, as required, to accommodate more.
+func New[T any](sz int, less func(a, b T) bool) *Heap[T] {
+	if sz < 1 {
+		sz = 1
+	}
+	return &Heap[T]{less: less, sz: sz, b: make([]*Handle[T], 0, sz)}
+}
+
+// NewMin creates and returns a new min-heap for ordered element
+// types. See New.
+func NewMin[T cmp.Ordered](sz int) *Heap[T] {
+	return New(sz, cmp.Less[T])
+}
+
+// NewMax creates and returns a new max-heap for ordered element
+// types. See New.
+func NewMax[T cmp.Ordered](sz int) *Heap[T] {
+	return New(sz, func(a, b T) bool { return cmp.Less(b, a) })
+}
+
+// NewTopK creates and returns a new bounded heap that holds at most k
+// elements (top-K mode). When the heap is full, pushing an element
+// that is "greater" (according to less) than the top element replaces
+// it, while pushing one that is not is rejected. As a result, the heap
+// holds the k "greatest" elements pushed so far. The space for all k
+// elements is allocated when the heap is created. NewTopK panics if k
+// < 1.
+func NewTopK[T any](k int, less func(a, b T) bool) *Heap[T] {
+	if k < 1 {
+		panic("Invalid heap size")
+	}
+	h := New(k, less)
+	h.k = k
+	return h
+}
+
+// Len returns the number of elements in the heap.
+func (h *Heap[T]) Len() int {
+	return len(h.b)
+}
+
+// Empty tests if the heap is empty.
+func (h *Heap[T]) Empty() bool {
+	return len(h.b) == 0
+}
+
+// Cap returns the capacity of the heap (# of element slots currently
+// allocated).
+func (h *Heap[T]) Cap() int {
+	return cap(h.b)
+}
+
+// Push adds element v to the heap, and returns a handle for it. In
+// top-K mode (see NewTopK), if the heap is full, and v is not
+// "greater" than the top element, v is rejected and Push returns
+// nil. Otherwise the top element is removed to make room for v.
+func (h *Heap[T]) Push(v T) *Handle[T] {
+	hd := &Handle[T]{v: v}
+	if h.k != 0 && len(h.b) == h.k {
+		if !h.less(h.b[0].v, v) {
+			return nil
+		}
+		h.b[0].i = -1
+		hd.i = 0
+		h.b[0] = hd
+		h.down(0)
+		return hd
+	}
+	if len(h.b) == cap(h.b) {
+		h.resize(2 * cap(h.b))
+	}
+	hd.i = len(h.b)
+	h.b = append(h.b, hd)
+	h.up(hd.i)
+	return hd
+}
+
+// Peek returns the top element of the heap, without removing
+// it. Returns ok == false if the heap is empty (unable to peek
+// element), ok == true otherwise.
+func (h *Heap[T]) Peek() (v T, ok bool) {
+	if len(h.b) == 0 {
+		return v, false
+	}
+	return h.b[0].v, true
+}
+
+// PeekHandle returns the handle of the top element of the heap, or nil
+// if the heap is empty.
+func (h *Heap[T]) PeekHandle() *Handle[T] {
+	if len(h.b) == 0 {
+		return nil
+	}
+	return h.b[0]
+}
+
+// Pop removes the top element from the heap and returns it. Returns ok
+// == false if the heap was empty (unable to pop element), ok == true
+// otherwise.
+func (h *Heap[T]) Pop() (v T, ok bool) {
+	if len(h.b) == 0 {
+		return v, false
+	}
+	return h.remove(0).v, true
+}
+
+// Remove removes the element identified by hd from the heap. Returns
+// ok == false if the element was not in the heap (e.g. it has already
+// been removed), ok == true otherwise.
+func (h *Heap[T]) Remove(hd *Handle[T]) (ok bool) {
+	if !h.has(hd) {
+		return false
+	}
+	h.remove(hd.i)
+	return true
+}
+
+// Update replaces the value of the element identified by hd with v, and
+// restores the heap ordering. Returns ok == false if the element was
+// not in the heap, ok == true otherwise.
+func (h *Heap[T]) Update(hd *Handle[T], v T) (ok bool) {
+	if !h.has(hd) {
+		return false
+	}
+	hd.v = v
+	h.fix(hd.i)
+	return true
+}
+
+// Fix restores the heap ordering after the priority of the element
+// identified by hd has changed (this is useful when the elements are
+// pointers, and the fields that determine their ordering are modified
+// in-place). Returns ok == false if the element was not in the heap,
+// ok == true otherwise.
+func (h *Heap[T]) Fix(hd *Handle[T]) (ok bool) {
+	if !h.has(hd) {
+		return false
+	}
+	h.fix(hd.i)
+	return true
+}
+
+// Clear removes all elements from the heap. It does not change its
+// capacity.
+func (h *Heap[T]) Clear() {
+	for _, hd := range h.b {
+		hd.i = -1
+	}
+	clear(h.b)
+	h.b = h.b[:0]
+}
+
+// Compact resizes the heap storage (without removing elements from
+// the heap) to the smallest possible size, but not smaller than sz. In
+// top-K mode the storage is never shrunk below k elements.
+func (h *Heap[T]) Compact(sz int) {
+	if sz < len(h.b) {
+		sz = len(h.b)
+	}
+	if sz < h.k {
+		sz = h.k
+	}
+	if sz < 1 {
+		sz = 1
+	}
+	if sz != cap(h.b) {
+		h.resize(sz)
+	}
+}
+
+// has tests if hd identifies an element of h.
+func (h *Heap[T]) has(hd *Handle[T]) bool {
+	return hd != nil && hd.i >= 0 && hd.i < len(h.b) && h.b[hd.i] == hd
+}
+
+// remove removes the i'th element from the heap and returns its
+// handle.
+func (h *Heap[T]) remove(i int) *Handle[T] {
+	n := len(h.b) - 1
+	hd := h.b[i]
+	if i != n {
+		h.swap(i, n)
+	}
+	h.b[n] = nil
+	h.b = h.b[:n]
+	if i != n {
+		h.fix(i)
+	}
+	hd.i = -1
+	return hd
+}
+
+// resize re-allocates the heap storage with capacity sz. The caller
+// must make sure that sz >= h.Len().
+func (h *Heap[T]) resize(sz int) {
+	b := make([]*Handle[T], len(h.b), sz)
+	copy(b, h.b)
+	h.b = b
+}
+
+func (h *Heap[T]) swap(i, j int) {
+	h.b[i], h.b[j] = h.b[j], h.b[i]
+	h.b[i].i = i
+	h.b[j].i = j
+}
+
+// fix moves the i'th element up or down the heap, as required, to
+// restore the heap ordering.
+func (h *Heap[T]) fix(i int) {
+	if !h.down(i) {
+		h.up(i)
+	}
+}
+
+func (h *Heap[T]) up(i int) {
+	for i > 0 {
+		p := (i - 1) / 2
+		if !h.less(h.b[i].v, h.b[p].v) {
+			break
+		}
+		h.swap(i, p)
+		i = p
+	}
+}
+
+// down moves the i'th element down the heap. Returns true if the
+// element was moved.
+func (h *Heap[T]) down(i int) bool {
+	i0, n := i, len(h.b)
+	for {
+		c := 2*i + 1
+		if c >= n || c < 0 {
+			break
+		}
+		if r := c + 1; r < n && h.less(h.b[r].v, h.b[c].v) {
+			c = r
+		}
+		if !h.less(h.b[c].v, h.b[i].v) {
+			break
+		}
+		h.swap(i, c)
+		i = c
+	}
+	return i > i0
+}
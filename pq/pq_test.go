@@ -0,0 +1,182 @@
+package pq_test
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/npat-efault/gohacks/pq"
+)
+
+func popAll(h *pq.Heap[int]) []int {
+	var s []int
+	for !h.Empty() {
+		v, _ := h.Pop()
+		s = append(s, v)
+	}
+	return s
+}
+
+func TestHeapOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	in := make([]int, 1000)
+	for i := range in {
+		in[i] = r.Intn(500)
+	}
+	hmin, hmax := pq.NewMin[int](1), pq.NewMax[int](1)
+	for _, v := range in {
+		hmin.Push(v)
+		hmax.Push(v)
+	}
+	if hmin.Len() != len(in) || hmin.Cap() != 1024 {
+		t.Fatalf("Heap len %d, cap %d", hmin.Len(), hmin.Cap())
+	}
+	if v, ok := hmin.Peek(); !ok || v != slices.Min(in) {
+		t.Fatalf("Peek %d != %d", v, slices.Min(in))
+	}
+	sorted := slices.Clone(in)
+	slices.Sort(sorted)
+	if out := popAll(hmin); !slices.Equal(out, sorted) {
+		t.Fatal("Bad min-heap order")
+	}
+	slices.Reverse(sorted)
+	if out := popAll(hmax); !slices.Equal(out, sorted) {
+		t.Fatal("Bad max-heap order")
+	}
+	if _, ok := hmin.Pop(); ok {
+		t.Fatal("Pop from empty heap")
+	}
+	if _, ok := hmin.Peek(); ok {
+		t.Fatal("Peek from empty heap")
+	}
+}
+
+func TestHeapHandles(t *testing.T) {
+	h := pq.NewMin[int](4)
+	hds := make([]*pq.Handle[int], 100)
+	for i := range hds {
+		hds[i] = h.Push(i)
+	}
+	// Remove odd elements, and negate multiples of 10.
+	for i := 1; i < 100; i += 2 {
+		if !h.Remove(hds[i]) {
+			t.Fatalf("Cannot remove %d", i)
+		}
+	}
+	if h.Remove(hds[1]) {
+		t.Fatal("Removed element twice")
+	}
+	for i := 0; i < 100; i += 10 {
+		if !h.Update(hds[i], -i) {
+			t.Fatalf("Cannot update %d", i)
+		}
+	}
+	var want []int
+	for i := 90; i >= 0; i -= 10 {
+		want = append(want, -i)
+	}
+	for i := 2; i < 100; i += 2 {
+		if i%10 != 0 {
+			want = append(want, i)
+		}
+	}
+	if out := popAll(h); !slices.Equal(out, want) {
+		t.Fatalf("Bad heap order: %v", out)
+	}
+	if h.Update(hds[0], 0) || h.Fix(hds[0]) {
+		t.Fatal("Updated removed element")
+	}
+}
+
+type task struct {
+	name string
+	prio int
+}
+
+func TestHeapFix(t *testing.T) {
+	h := pq.New(1, func(a, b *task) bool { return a.prio < b.prio })
+	tasks := []*task{{"a", 3}, {"b", 2}, {"c", 1}}
+	hds := make([]*pq.Handle[*task], len(tasks))
+	for i, tk := range tasks {
+		hds[i] = h.Push(tk)
+	}
+	tasks[0].prio = 0
+	h.Fix(hds[0])
+	tasks[2].prio = 5
+	h.Fix(hds[2])
+	var out string
+	for !h.Empty() {
+		tk, _ := h.Pop()
+		out += tk.name
+	}
+	if out != "abc" {
+		t.Fatalf("Bad heap order: %s", out)
+	}
+}
+
+func TestTopK(t *testing.T) {
+	h := pq.NewTopK(5, func(a, b int) bool { return a < b })
+	in := rand.New(rand.NewSource(2)).Perm(100)
+	for _, v := range in {
+		h.Push(v)
+	}
+	if h.Len() != 5 || h.Cap() != 5 {
+		t.Fatalf("Heap len %d, cap %d", h.Len(), h.Cap())
+	}
+	if hd := h.Push(0); hd != nil {
+		t.Fatal("Small element not rejected")
+	}
+	if out := popAll(h); !slices.Equal(out, []int{95, 96, 97, 98, 99}) {
+		t.Fatalf("Bad top-K: %v", out)
+	}
+}
+
+func TestHeapCompact(t *testing.T) {
+	h := pq.NewMin[int](2)
+	for i := 0; i < 100; i++ {
+		h.Push(i)
+	}
+	for i := 0; i < 90; i++ {
+		h.Pop()
+	}
+	if h.Cap() != 128 {
+		t.Fatalf("Heap cap %d != 128", h.Cap())
+	}
+	h.Compact(0)
+	if h.Cap() != 10 {
+		t.Fatalf("Heap cap %d != 10", h.Cap())
+	}
+	h.Compact(64)
+	if h.Cap() != 64 {
+		t.Fatalf("Heap cap %d != 64", h.Cap())
+	}
+	if out := popAll(h); !slices.Equal(out, []int{90, 91, 92, 93, 94,
+		95, 96, 97, 98, 99}) {
+		t.Fatalf("Bad heap after compact: %v", out)
+	}
+}
+
+func Example() {
+	// A max-heap of jobs, ordered by priority.
+	type job struct {
+		name string
+		prio int
+	}
+	h := pq.New(8, func(a, b job) bool { return a.prio > b.prio })
+	h.Push(job{"backup", 1})
+	hd := h.Push(job{"report", 2})
+	h.Push(job{"deploy", 3})
+
+	// Raise the priority of "report".
+	h.Update(hd, job{"report", 4})
+
+	for !h.Empty() {
+		j, _ := h.Pop()
+		fmt.Println(j.name, j.prio)
+	}
+	// Output:
+	// report 4
+	// deploy 3
+	// backup 1
+}
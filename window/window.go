@@ -0,0 +1,238 @@
+// Copyright (c) 2016, Nick Patavalis (npat@efault.net).
+// All rights reserved.
+// Use of this source code is governed by a BSD-style license that can
+// be found in the LICENSE.txt file.
+
+// Package window provides sliding-window aggregators over numeric
+// samples. A window keeps either the last N samples added to it, or
+// the samples added during the last duration D (according to their
+// timestamps), and maintains running aggregates over them: Sum and
+// Mean, and, using monotonic deques, Min and Max, all in O(1)
+// amortized time per sample. Quantiles can be computed on demand.
+//
+// Samples are kept in queues from package cirq. Window operations are
+// *NOT* thread safe.
+package window
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/npat-efault/gohacks/cirq"
+)
+
+// Number is the constraint for the types of samples that can be
+// aggregated by a Window.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Sample is a timestamped sample value.
+type Sample[T Number] struct {
+	At time.Time
+	V  T
+}
+
+// extremum is an entry of a monotonic deque.
+type extremum[T Number] struct {
+	seq uint64 /* sequence number of the sample */
+	v   T
+}
+
+// Window is a sliding-window aggregator. It is created either as a
+// count window (see NewCount), or as a duration window (see
+// NewDuration).
+//
+// The Sum is maintained by adding the values of samples as they enter
+// the window and subtracting them as they leave it. For
+// floating-point samples this may accumulate rounding errors over
+// long periods; Reset can be used to clear them.
+type Window[T Number] struct {
+	n   int           /* max # of samples (count window), or 0 */
+	d   time.Duration /* max sample age (duration window), or 0 */
+	q   *cirq.Queue[Sample[T]]
+	sum T
+	seq uint64                   /* sequence number of the next sample */
+	mn  *cirq.Queue[extremum[T]] /* min candidates, increasing */
+	mx  *cirq.Queue[extremum[T]] /* max candidates, decreasing */
+}
+
+// NewCount creates and returns a new count window, holding the last n
+// samples added to it. It panics if n < 1.
+func NewCount[T Number](n int) *Window[T] {
+	if n < 1 {
+		panic("Invalid window size")
+	}
+	return &Window[T]{
+		n:  n,
+		q:  cirq.NewQueue[Sample[T]](n, n),
+		mn: cirq.NewQueue[extremum[T]](n, n),
+		mx: cirq.NewQueue[extremum[T]](n, n),
+	}
+}
+
+// NewDuration creates and returns a new duration window, holding the
+// samples added to it during the last duration d (that is, samples
+// with timestamps in the interval (t - d, t], where t is the timestamp
+// of the latest sample, or the time given to Expire). Samples must be
+// added in timestamp order. Storage for the samples grows and shrinks
+// as required. It panics if d <= 0.
+func NewDuration[T Number](d time.Duration) *Window[T] {
+	if d <= 0 {
+		panic("Invalid window duration")
+	}
+	mkq := func() *cirq.Queue[extremum[T]] {
+		q, _ := cirq.NewQueueOpts[extremum[T]](16, cirq.Unbounded,
+			&cirq.Options{Shrink: true})
+		return q
+	}
+	q, _ := cirq.NewQueueOpts[Sample[T]](16, cirq.Unbounded,
+		&cirq.Options{Shrink: true})
+	return &Window[T]{d: d, q: q, mn: mkq(), mx: mkq()}
+}
+
+// Add adds a sample with value v, timestamped with the current time,
+// to the window. See AddAt.
+func (w *Window[T]) Add(v T) {
+	w.AddAt(v, time.Now())
+}
+
+// AddAt adds a sample with value v and timestamp t to the window. For
+// count windows, if the window is full, the oldest sample is
+// removed. For duration windows, samples older than t - d are
+// removed.
+func (w *Window[T]) AddAt(v T, t time.Time) {
+	if w.n != 0 {
+		if w.q.Len() == w.n {
+			w.evict()
+		}
+	} else {
+		w.Expire(t)
+	}
+	w.q.PushBack(Sample[T]{At: t, V: v})
+	w.sum += v
+	e := extremum[T]{seq: w.seq, v: v}
+	w.seq++
+	for b, ok := w.mn.PeekBack(); ok && b.v >= v; b, ok = w.mn.PeekBack() {
+		w.mn.PopBack()
+	}
+	w.mn.PushBack(e)
+	for b, ok := w.mx.PeekBack(); ok && b.v <= v; b, ok = w.mx.PeekBack() {
+		w.mx.PopBack()
+	}
+	w.mx.PushBack(e)
+}
+
+// Expire removes from a duration window the samples that are older
+// than now - d (where d is the window's duration). It is useful for
+// querying the window after a period of no activity. For count
+// windows it does nothing.
+func (w *Window[T]) Expire(now time.Time) {
+	if w.d == 0 {
+		return
+	}
+	lim := now.Add(-w.d)
+	for s, ok := w.q.PeekFront(); ok && !s.At.After(lim); s, ok =
+		w.q.PeekFront() {
+		w.evict()
+	}
+}
+
+// evict removes the oldest sample from the window.
+func (w *Window[T]) evict() {
+	s, _ := w.q.PopFront()
+	w.sum -= s.V
+	seq := w.seq - uint64(w.q.Len()) - 1
+	if e, ok := w.mn.PeekFront(); ok && e.seq == seq {
+		w.mn.PopFront()
+	}
+	if e, ok := w.mx.PeekFront(); ok && e.seq == seq {
+		w.mx.PopFront()
+	}
+}
+
+// Reset removes all samples from the window.
+func (w *Window[T]) Reset() {
+	for !w.q.Empty() {
+		w.q.PopFront()
+	}
+	for !w.mn.Empty() {
+		w.mn.PopFront()
+	}
+	for !w.mx.Empty() {
+		w.mx.PopFront()
+	}
+	w.sum = 0
+}
+
+// Len returns the number of samples in the window.
+func (w *Window[T]) Len() int {
+	return w.q.Len()
+}
+
+// Sum returns the sum of the samples in the window (0 if the window is
+// empty).
+func (w *Window[T]) Sum() T {
+	return w.sum
+}
+
+// Mean returns the arithmetic mean of the samples in the
+// window. Returns ok == false if the window is empty, ok == true
+// otherwise.
+func (w *Window[T]) Mean() (m float64, ok bool) {
+	if w.q.Empty() {
+		return 0, false
+	}
+	return float64(w.sum) / float64(w.q.Len()), true
+}
+
+// Min returns the least sample value in the window. Returns ok ==
+// false if the window is empty, ok == true otherwise.
+func (w *Window[T]) Min() (v T, ok bool) {
+	e, ok := w.mn.PeekFront()
+	return e.v, ok
+}
+
+// Max returns the greatest sample value in the window. Returns ok ==
+// false if the window is empty, ok == true otherwise.
+func (w *Window[T]) Max() (v T, ok bool) {
+	e, ok := w.mx.PeekFront()
+	return e.v, ok
+}
+
+// Quantile returns the q-quantile (0 <= q <= 1) of the sample values
+// in the window, using the nearest-rank method: the smallest value v
+// such that at least a fraction q of the values are less than or
+// equal to v (for q == 0, the least value). Unlike the other
+// aggregates, Quantile is computed on demand, over a sorted copy of
+// the values, and takes O(n log n) time. Returns ok == false if the
+// window is empty, ok == true otherwise. It panics if q is out of
+// range.
+func (w *Window[T]) Quantile(q float64) (v T, ok bool) {
+	if q < 0 || q > 1 {
+		panic("Invalid quantile")
+	}
+	n := w.q.Len()
+	if n == 0 {
+		return v, false
+	}
+	vs := make([]T, 0, n)
+	for _, s := range w.q.All() {
+		vs = append(vs, s.V)
+	}
+	slices.Sort(vs)
+	i := int(math.Ceil(q*float64(n))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return vs[i], true
+}
+
+// Samples returns a new slice holding the samples in the window, from
+// oldest to newest.
+func (w *Window[T]) Samples() []Sample[T] {
+	return w.q.Slice()
+}
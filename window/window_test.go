@@ -0,0 +1,127 @@
+package window_test
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/npat-efault/gohacks/window"
+)
+
+// check compares the aggregates of w against those computed directly
+// from vs.
+func check(t *testing.T, w *window.Window[int], vs []int) {
+	t.Helper()
+	if w.Len() != len(vs) {
+		t.Fatalf("Len %d != %d", w.Len(), len(vs))
+	}
+	sum := 0
+	for _, v := range vs {
+		sum += v
+	}
+	if w.Sum() != sum {
+		t.Fatalf("Sum %d != %d", w.Sum(), sum)
+	}
+	mn, okMn := w.Min()
+	mx, okMx := w.Max()
+	m, okM := w.Mean()
+	if len(vs) == 0 {
+		if okMn || okMx || okM {
+			t.Fatal("Aggregates of empty window")
+		}
+		return
+	}
+	if mn != slices.Min(vs) || mx != slices.Max(vs) {
+		t.Fatalf("Min/Max %d/%d != %d/%d",
+			mn, mx, slices.Min(vs), slices.Max(vs))
+	}
+	if m != float64(sum)/float64(len(vs)) {
+		t.Fatalf("Mean %v != %v", m, float64(sum)/float64(len(vs)))
+	}
+}
+
+func TestCount(t *testing.T) {
+	const n = 37
+	w := window.NewCount[int](n)
+	check(t, w, nil)
+	var vs []int
+	for i := 0; i < 1000; i++ {
+		v := rand.Intn(100) - 50
+		w.Add(v)
+		vs = append(vs, v)
+		if len(vs) > n {
+			vs = vs[1:]
+		}
+		check(t, w, vs)
+	}
+	w.Reset()
+	check(t, w, nil)
+	w.Add(3)
+	check(t, w, []int{3})
+}
+
+func TestDuration(t *testing.T) {
+	const d = 100 * time.Millisecond
+	w := window.NewDuration[int](d)
+	t0 := time.Unix(1000, 0)
+	var ss []window.Sample[int]
+	now := t0
+	for i := 0; i < 2000; i++ {
+		now = now.Add(time.Duration(rand.Intn(10)) * time.Millisecond)
+		v := rand.Intn(1000)
+		w.AddAt(v, now)
+		ss = append(ss, window.Sample[int]{At: now, V: v})
+		for !ss[0].At.After(now.Add(-d)) {
+			ss = ss[1:]
+		}
+		vs := make([]int, len(ss))
+		for j := range ss {
+			vs[j] = ss[j].V
+		}
+		check(t, w, vs)
+	}
+	if s := w.Samples(); !slices.Equal(s, ss) {
+		t.Fatalf("Samples %v != %v", s, ss)
+	}
+	w.Expire(now.Add(d - time.Millisecond))
+	if w.Len() == 0 {
+		t.Fatal("Expired too early")
+	}
+	w.Expire(now.Add(d))
+	check(t, w, nil)
+}
+
+func TestQuantile(t *testing.T) {
+	w := window.NewCount[int](100)
+	if _, ok := w.Quantile(0.5); ok {
+		t.Fatal("Quantile of empty window")
+	}
+	for _, i := range rand.Perm(100) {
+		w.Add(i + 1)
+	}
+	for _, c := range []struct {
+		q float64
+		v int
+	}{{0, 1}, {0.01, 1}, {0.5, 50}, {0.505, 51}, {0.99, 99}, {1, 100}} {
+		if v, _ := w.Quantile(c.q); v != c.v {
+			t.Fatalf("Quantile %v: %d != %d", c.q, v, c.v)
+		}
+	}
+}
+
+func TestFloat(t *testing.T) {
+	w := window.NewCount[float64](2)
+	w.Add(1.5)
+	w.Add(-2.5)
+	w.Add(4)
+	if w.Sum() != 1.5 {
+		t.Fatalf("Sum %v != 1.5", w.Sum())
+	}
+	if m, _ := w.Mean(); m != 0.75 {
+		t.Fatalf("Mean %v != 0.75", m)
+	}
+	if v, _ := w.Min(); v != -2.5 {
+		t.Fatalf("Min %v != -2.5", v)
+	}
+}